@@ -3,7 +3,11 @@ package main
 // Example application demonstrating the usage of the quego server.
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Pelfox/quego"
@@ -27,9 +31,27 @@ func main() {
 		time.Sleep(10 * time.Second)
 		fmt.Println("Function completed!")
 		return nil
-	})
+	}, models.WithRetryPolicy(models.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		Multiplier:     2,
+		MaxBackoff:     30 * time.Second,
+		Jitter:         0.1,
+	}))
 
-	if err := server.Start(":8080"); err != nil {
-		panic(err)
+	go func() {
+		if err := server.Start(":8080"); err != nil {
+			panic(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("Shutdown did not complete cleanly:", err)
 	}
 }
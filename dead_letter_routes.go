@@ -0,0 +1,124 @@
+package quego
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Pelfox/quego/internal"
+	"github.com/Pelfox/quego/internal/services"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// listDeadLetters handles `GET /dead-letters` requests. It retrieves all
+// dead-lettered jobs, newest first, and returns them as JSON.
+func (s *Server) listDeadLetters(ctx echo.Context) error {
+	deadLetters, err := s.executionService.ListDeadLetters()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve dead letters")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeQueue,
+			"Failed to retrieve dead letters",
+		)
+	}
+	return ctx.JSON(http.StatusOK, deadLetters)
+}
+
+// getDeadLetter handles `GET /dead-letters/:id` requests. It retrieves a
+// single dead letter by its UUID and returns it as JSON.
+func (s *Server) getDeadLetter(ctx echo.Context) error {
+	deadLetterID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid dead letter ID")
+	}
+
+	deadLetter, err := s.executionService.GetDeadLetter(deadLetterID)
+	if err != nil {
+		log.Error().Err(err).Str("id", deadLetterID.String()).Msg("failed to get dead letter")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeQueue,
+			"Failed to retrieve dead letter",
+		)
+	}
+	if deadLetter == nil {
+		return internal.RespondError(
+			ctx,
+			http.StatusNotFound,
+			internal.ErrorCodeNotFound,
+			"Dead letter not found",
+		)
+	}
+
+	return ctx.JSON(http.StatusOK, deadLetter)
+}
+
+// requeueDeadLetter handles `POST /dead-letters/:id/requeue` requests. It
+// removes the dead letter and enqueues its execution again, returning the
+// execution as JSON.
+func (s *Server) requeueDeadLetter(ctx echo.Context) error {
+	deadLetterID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid dead letter ID")
+	}
+
+	execution, err := s.executionService.RequeueDeadLetter(deadLetterID)
+	switch {
+	case err == nil:
+		return ctx.JSON(http.StatusOK, execution)
+	case errors.Is(err, services.ErrDeadLetterNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusNotFound,
+			internal.ErrorCodeNotFound,
+			"Dead letter not found",
+		)
+	case errors.Is(err, services.ErrExecutionNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusNotFound,
+			internal.ErrorCodeNotFound,
+			"The execution of the dead letter no longer exists",
+		)
+	case errors.Is(err, services.ErrDeadLetterMalformed):
+		return internal.RespondError(
+			ctx,
+			http.StatusConflict,
+			internal.ErrorCodeMalformedJob,
+			"The dead letter holds a malformed job and cannot be requeued",
+		)
+	case errors.Is(err, services.ErrFunctionNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusConflict,
+			internal.ErrorCodeFunctionNotFound,
+			"The requested function is not registered",
+		)
+	}
+
+	log.Error().Err(err).Str("id", deadLetterID.String()).Msg("failed to requeue dead letter")
+	return internal.RespondError(
+		ctx,
+		http.StatusInternalServerError,
+		internal.ErrorCodeQueue,
+		"Failed to requeue dead letter",
+	)
+}
+
+// purgeDeadLetters handles `DELETE /dead-letters` requests. It removes every
+// job from the dead-letter queue.
+func (s *Server) purgeDeadLetters(ctx echo.Context) error {
+	if err := s.executionService.PurgeDeadLetters(); err != nil {
+		log.Error().Err(err).Msg("failed to purge dead letters")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeQueue,
+			"Failed to purge dead letters",
+		)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
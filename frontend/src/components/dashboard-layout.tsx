@@ -1,6 +1,7 @@
 import type { PropsWithChildren } from 'react';
 import type { KeyedMutator } from 'swr';
 import type z from 'zod';
+import type { RegisteredFunction } from '@/types/function';
 import { Sidebar } from '@components/sidebar/sidebar';
 import { zodResolver } from '@hookform/resolvers/zod';
 import { DialogContent, DialogDescription, DialogOverlay, DialogPortal, DialogTitle, DialogTrigger, Root } from '@radix-ui/react-dialog';
@@ -11,11 +12,13 @@ import { Loader2Icon, RefreshCcwIcon } from 'lucide-react';
 import { useState } from 'react';
 import { FormProvider, useForm } from 'react-hook-form';
 import { toast } from 'sonner';
+import useSWR from 'swr';
 import { testTriggerSchema } from '@/lib/schemas';
 
 export function DashoardLayout({ title, mutate, children }: { title: string; mutate: KeyedMutator<any> } & PropsWithChildren) {
   const [isMutating, setIsMutating] = useState(false);
   const [isLoading, setIsLoading] = useState(false);
+  const { data: functions } = useSWR<RegisteredFunction[]>('/functions');
 
   const form = useForm({
     resolver: zodResolver(testTriggerSchema),
@@ -81,7 +84,14 @@ export function DashoardLayout({ title, mutate, children }: { title: string; mut
                           name="function_name"
                           control={form.control}
                           render={field => (
-                            <Input {...field} type="text" placeholder="function-name" />
+                            <>
+                              <Input {...field} type="text" placeholder="function-name" list="registered-functions" />
+                              <datalist id="registered-functions">
+                                {functions?.map(fn => (
+                                  <option key={fn.name} value={fn.name}>{fn.description}</option>
+                                ))}
+                              </datalist>
+                            </>
                           )}
                         />
                         <FormField
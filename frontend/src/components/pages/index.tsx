@@ -3,15 +3,52 @@ import { DashoardLayout } from '@components/dashboard-layout';
 import { getBadgeIcon, getBadgeType } from '@lib/badge';
 import { formatDuration } from '@lib/format';
 import { Badge } from '@ui/badge';
+import { Button } from '@ui/button';
 import { TableBody, TableCell, TableHeader, TableHeaderCell, TableRoot, TableRow } from '@ui/table';
 import { Loader2Icon, ServerCrashIcon } from 'lucide-react';
-import useSWR from 'swr';
+import useSWRInfinite from 'swr/infinite';
+
+interface ExecutionsPage {
+  executions: Execution[];
+  // nextCursor is the value of the `Next-Cursor` header, which is only set if
+  // more executions follow this page.
+  nextCursor: string | null;
+}
+
+async function fetchExecutionsPage(url: string): Promise<ExecutionsPage> {
+  const res = await fetch(`${import.meta.env.VITE_API_URL}${url}`);
+  if (!res.ok) {
+    const error = await res.json().catch(() => null);
+    throw new Error(error?.message ?? `Request failed with status ${res.status}`);
+  }
+  return {
+    executions: await res.json(),
+    nextCursor: res.headers.get('Next-Cursor'),
+  };
+}
+
+function getExecutionsPageKey(pageIndex: number, previousPage: ExecutionsPage | null) {
+  if (pageIndex === 0) {
+    return '/executions';
+  }
+  if (!previousPage?.nextCursor) {
+    return null;
+  }
+  return `/executions?cursor=${encodeURIComponent(previousPage.nextCursor)}`;
+}
 
 export function IndexPage() {
-  const { data, isLoading, error, mutate } = useSWR('/executions', {
-    refreshInterval: 5000,
-    revalidateOnFocus: true,
-  });
+  const { data, size, setSize, isLoading, isValidating, error, mutate } = useSWRInfinite(
+    getExecutionsPageKey,
+    fetchExecutionsPage,
+    {
+      refreshInterval: 5000,
+      revalidateOnFocus: true,
+    },
+  );
+  const executions = data?.flatMap(page => page.executions) ?? [];
+  const hasMore = !!data?.[data.length - 1]?.nextCursor;
+  const isLoadingMore = isValidating && data?.[size - 1] === undefined;
 
   return (
     <DashoardLayout title="Workflows" mutate={mutate}>
@@ -23,7 +60,8 @@ export function IndexPage() {
           <p className="text-sm text-neutral-400">
             Showing
             {' '}
-            {data?.length ?? 0}
+            {executions.length}
+            {hasMore ? ' most recent' : ''}
             {' '}
             results
           </p>
@@ -53,7 +91,7 @@ export function IndexPage() {
               <TableHeaderCell>Trigger</TableHeaderCell>
             </TableHeader>
             <TableBody>
-              {data && data.map((execution: Execution) => (
+              {executions.map(execution => (
                 <TableRow key={execution.id}>
                   <TableCell>{execution.id}</TableCell>
                   <TableCell>{execution.trigger.function_name}</TableCell>
@@ -74,6 +112,14 @@ export function IndexPage() {
             </TableBody>
           </TableRoot>
         )}
+        {(!isLoading && !error && hasMore) && (
+          <div className="flex items-center justify-center w-full border-t border-neutral-800 px-6 py-4">
+            <Button size="sm" onClick={() => setSize(size + 1)} disabled={isLoadingMore}>
+              {isLoadingMore && <Loader2Icon className="animate-spin" size="16" />}
+              Load more
+            </Button>
+          </div>
+        )}
       </div>
     </DashoardLayout>
   );
@@ -1,14 +1,19 @@
 import type { Execution } from '@/types/execution';
-import { CheckIcon, FileStackIcon, Loader2Icon, ServerCrashIcon } from 'lucide-react';
+import { BanIcon, CheckIcon, FileStackIcon, Loader2Icon, ServerCrashIcon } from 'lucide-react';
 
 export function getBadgeType(status: Execution['status']) {
   switch (status) {
     case 'COMPLETED':
       return 'success';
     case 'FAILED':
+    case 'TIMED_OUT':
+    case 'DEAD':
       return 'danger';
     case 'PENDING':
       return 'medium';
+    case 'CANCELLED':
+    case 'SKIPPED':
+      return 'neutral';
     case 'RUNNING':
     default:
       return 'neutral';
@@ -20,9 +25,14 @@ export function getBadgeIcon(status: Execution['status']) {
     case 'COMPLETED':
       return CheckIcon;
     case 'FAILED':
+    case 'TIMED_OUT':
+    case 'DEAD':
       return ServerCrashIcon;
     case 'PENDING':
       return FileStackIcon;
+    case 'CANCELLED':
+    case 'SKIPPED':
+      return BanIcon;
     case 'RUNNING':
     default:
       return Loader2Icon;
@@ -4,7 +4,14 @@ export interface Execution {
   id: string;
   trigger_id: string;
   trigger: Trigger;
-  status: 'PENDING' | 'RUNNING' | 'COMPLETED' | 'FAILED';
+  status: 'PENDING' | 'RUNNING' | 'COMPLETED' | 'FAILED' | 'TIMED_OUT' | 'CANCELLED' | 'SKIPPED' | 'DEAD';
+  attempt: number;
+  priority: number;
+  created_at?: string;
+  scheduled_at?: string;
   started_at?: string;
   finished_at?: string;
+  result?: unknown;
+  error?: string;
+  stack_trace?: string;
 }
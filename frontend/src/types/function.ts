@@ -0,0 +1,19 @@
+export interface RegisteredFunction {
+  name: string;
+  description?: string;
+  tags: string[];
+  payload_schema?: unknown;
+  queue: string;
+  retry: {
+    max_attempts: number;
+    initial_backoff: string;
+    multiplier: number;
+    max_backoff?: string;
+    jitter: number;
+  };
+  timeout?: string;
+  concurrency: 'ALLOW' | 'SKIP' | 'QUEUE' | 'REPLACE';
+  max_concurrency: number;
+  pending: number;
+  running: number;
+}
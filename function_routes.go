@@ -0,0 +1,26 @@
+package quego
+
+import (
+	"net/http"
+
+	"github.com/Pelfox/quego/internal"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// listFunctions handles `GET /functions` requests. It returns every
+// registered function as JSON, sorted by name, along with its settings and
+// the number of its executions that are pending or running.
+func (s *Server) listFunctions(ctx echo.Context) error {
+	functions, err := s.executionService.ListFunctions()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve functions")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeDatabase,
+			"Failed to retrieve functions",
+		)
+	}
+	return ctx.JSON(http.StatusOK, functions)
+}
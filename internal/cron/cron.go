@@ -0,0 +1,231 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExpression is returned when a CRON expression cannot be parsed.
+var ErrInvalidExpression = errors.New("invalid cron expression")
+
+// searchYears limits how far into the future `Next` looks for a matching
+// time. Expressions such as `0 0 30 2 *` never match, so the search must stop
+// at some point.
+const searchYears = 5
+
+// maxSearchSteps is a hard limit on the steps taken by a single search: the
+// number of minutes in the years it covers.
+const maxSearchSteps = (searchYears + 1) * 366 * 24 * 60
+
+// field describes the allowed range and the optional symbolic names of a
+// single CRON field.
+type field struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors maps the supported `@` shortcuts to their five-field form.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Schedule is a parsed CRON expression. Each field is stored as a bitset in
+// which bit N is set when the value N matches.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields were unrestricted,
+	// which changes how they are combined (see `dayMatches`).
+	domStar, dowStar bool
+}
+
+// Parse parses a standard five-field CRON expression (minute, hour, day of
+// month, month, day of week) or one of the `@hourly`-style descriptors.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		expanded, ok := descriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown descriptor %q", ErrInvalidExpression, expr)
+		}
+		expr = expanded
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidExpression, len(fields))
+	}
+
+	var (
+		schedule Schedule
+		err      error
+	)
+	if schedule.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if schedule.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if schedule.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	// Sunday may be written as either 0 or 7.
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domStar = fields[2] == "*" || fields[2] == "?"
+	schedule.dowStar = fields[4] == "*" || fields[4] == "?"
+	return &schedule, nil
+}
+
+// parseField parses a comma-separated list of values, ranges and steps into
+// a bitset for the given field.
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(value, ",") {
+		partBits, err := parsePart(part, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+// parsePart parses a single list element such as `*`, `5`, `1-5`, `*/15` or
+// `mon-fri/2`.
+func parsePart(part string, f field) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+	step := uint(1)
+	if hasStep {
+		parsed, err := strconv.ParseUint(stepPart, 10, 8)
+		if err != nil || parsed == 0 {
+			return 0, fmt.Errorf("%w: invalid step %q in %s field", ErrInvalidExpression, stepPart, f.name)
+		}
+		step = uint(parsed)
+	}
+
+	var start, end uint
+	switch {
+	case rangePart == "*" || rangePart == "?":
+		start, end = f.min, f.max
+	case strings.Contains(rangePart, "-"):
+		low, high, _ := strings.Cut(rangePart, "-")
+		var err error
+		if start, err = parseValue(low, f); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(high, f); err != nil {
+			return 0, err
+		}
+		if start > end {
+			return 0, fmt.Errorf("%w: invalid range %q in %s field", ErrInvalidExpression, rangePart, f.name)
+		}
+	default:
+		value, err := parseValue(rangePart, f)
+		if err != nil {
+			return 0, err
+		}
+		start, end = value, value
+		// `5/10` means "starting at 5, every 10 units".
+		if hasStep {
+			end = f.max
+		}
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits, nil
+}
+
+// parseValue parses a single numeric or symbolic value and checks it against
+// the bounds of the field.
+func parseValue(value string, f field) (uint, error) {
+	if named, ok := f.names[strings.ToLower(value)]; ok {
+		return named, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid value %q in %s field", ErrInvalidExpression, value, f.name)
+	}
+	if uint(parsed) < f.min || uint(parsed) > f.max {
+		return 0, fmt.Errorf(
+			"%w: value %d out of range [%d, %d] in %s field",
+			ErrInvalidExpression, parsed, f.min, f.max, f.name,
+		)
+	}
+	return uint(parsed), nil
+}
+
+// Next returns the earliest time strictly after `t` that matches the
+// schedule. Schedules are matched against the UTC wall clock, which has no
+// daylight saving transitions to skip or repeat times, so the returned time is
+// in UTC whatever the location of `t`. It returns the zero time if no such
+// time exists within the next few years (e.g. for `0 0 30 2 *`).
+func (s *Schedule) Next(t time.Time) time.Time {
+	limit := t.Year() + searchYears
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// Every step moves forward by at least a minute, which bounds the search
+	// even if the fields below were to stop making progress.
+	for steps := 0; steps < maxSearchSteps; steps++ {
+		switch {
+		case next.Year() > limit:
+			return time.Time{}
+		case s.month&(1<<uint(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(next.Hour())) == 0:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, time.UTC)
+		case s.minute&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of `t` satisfies the day-of-month and
+// day-of-week fields. Following the traditional CRON semantics, if both
+// fields are restricted a day matches when either of them does.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,229 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// bits returns a bitset with the given values set.
+func bits(values ...uint) uint64 {
+	var set uint64
+	for _, value := range values {
+		set |= 1 << value
+	}
+	return set
+}
+
+// span returns a bitset with the values from `start` to `end` set, every
+// `step` values.
+func span(start, end, step uint) uint64 {
+	var set uint64
+	for value := start; value <= end; value += step {
+		set |= 1 << value
+	}
+	return set
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Schedule
+	}{
+		{"* * * * *", Schedule{
+			minute: span(0, 59, 1), hour: span(0, 23, 1), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1),
+			domStar: true, dowStar: true,
+		}},
+		{"5 4 3 2 1", Schedule{
+			minute: bits(5), hour: bits(4), dom: bits(3), month: bits(2), dow: bits(1),
+		}},
+		{"0-10 9-17 1-15 6-8 1-5", Schedule{
+			minute: span(0, 10, 1), hour: span(9, 17, 1), dom: span(1, 15, 1), month: span(6, 8, 1), dow: span(1, 5, 1),
+		}},
+		{"*/15 */6 */10 */3 */2", Schedule{
+			minute: bits(0, 15, 30, 45), hour: bits(0, 6, 12, 18), dom: bits(1, 11, 21, 31), month: bits(1, 4, 7, 10),
+			dow: bits(0, 2, 4, 6),
+		}},
+		{"10-30/10 5/6 ? * *", Schedule{
+			minute: bits(10, 20, 30), hour: bits(5, 11, 17, 23), dom: span(1, 31, 1), month: span(1, 12, 1),
+			dow: span(0, 7, 1), domStar: true, dowStar: true,
+		}},
+		{"0,30 1,13 1,15,L * *", Schedule{}},
+		{"0,30 1,13 1,15 * *", Schedule{
+			minute: bits(0, 30), hour: bits(1, 13), dom: bits(1, 15), month: span(1, 12, 1), dow: span(0, 7, 1),
+			dowStar: true,
+		}},
+		{"0 0 * JAN,jul mon-FRI", Schedule{
+			minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: bits(1, 7), dow: span(1, 5, 1), domStar: true,
+		}},
+		{"0 0 * * 7", Schedule{
+			minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0, 7), domStar: true,
+		}},
+		{"0 0 * * sat-sun", Schedule{}},
+		{"  @Weekly ", Schedule{
+			minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0), domStar: true,
+		}},
+		{"@yearly", Schedule{
+			minute: bits(0), hour: bits(0), dom: bits(1), month: bits(1), dow: span(0, 7, 1), dowStar: true,
+		}},
+		{"@hourly", Schedule{
+			minute: bits(0), hour: span(0, 23, 1), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1),
+			domStar: true, dowStar: true,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			got, err := Parse(test.expr)
+			if test.want == (Schedule{}) {
+				if !errors.Is(err, ErrInvalidExpression) {
+					t.Fatalf("Parse(%q) = %+v, %v, want ErrInvalidExpression", test.expr, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.expr, err)
+			}
+			if *got != test.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", test.expr, *got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@reboot",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1-2-3 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"-1 * * * *",
+		"1, * * * *",
+	} {
+		if got, err := Parse(expr); !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("Parse(%q) = %+v, %v, want ErrInvalidExpression", expr, got, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse(time.DateTime, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "every minute",
+			expr: "* * * * *",
+			from: utc("2026-01-01 10:00:30"),
+			want: []time.Time{utc("2026-01-01 10:01:00"), utc("2026-01-01 10:02:00")},
+		},
+		{
+			name: "strictly after",
+			expr: "30 10 * * *",
+			from: utc("2026-01-01 10:30:00"),
+			want: []time.Time{utc("2026-01-02 10:30:00")},
+		},
+		{
+			name: "hour wraps into the next day",
+			expr: "15 9 * * *",
+			from: utc("2026-01-01 23:59:00"),
+			want: []time.Time{utc("2026-01-02 09:15:00")},
+		},
+		{
+			name: "year wrap",
+			expr: "0 0 1 1 *",
+			from: utc("2026-06-15 12:00:00"),
+			want: []time.Time{utc("2027-01-01 00:00:00"), utc("2028-01-01 00:00:00")},
+		},
+		{
+			name: "month ends",
+			expr: "0 12 31 * *",
+			from: utc("2026-01-31 12:00:00"),
+			want: []time.Time{utc("2026-03-31 12:00:00"), utc("2026-05-31 12:00:00"), utc("2026-07-31 12:00:00")},
+		},
+		{
+			name: "thirtieth skips february",
+			expr: "0 0 30 * *",
+			from: utc("2026-01-30 00:00:00"),
+			want: []time.Time{utc("2026-03-30 00:00:00")},
+		},
+		{
+			name: "leap days",
+			expr: "0 0 29 2 *",
+			from: utc("2026-01-01 00:00:00"),
+			want: []time.Time{utc("2028-02-29 00:00:00"), utc("2032-02-29 00:00:00")},
+		},
+		{
+			name: "never matches",
+			expr: "0 0 30 2 *",
+			from: utc("2026-01-01 00:00:00"),
+			want: []time.Time{{}},
+		},
+		{
+			name: "day of week",
+			expr: "0 9 * * mon-fri",
+			from: utc("2026-01-02 09:00:00"), // A Friday.
+			want: []time.Time{utc("2026-01-05 09:00:00"), utc("2026-01-06 09:00:00")},
+		},
+		{
+			name: "restricted day fields match either",
+			expr: "0 0 13 * 5",
+			from: utc("2026-02-01 00:00:00"),
+			want: []time.Time{utc("2026-02-06 00:00:00"), utc("2026-02-13 00:00:00"), utc("2026-02-20 00:00:00")},
+		},
+		{
+			name: "sunday as seven",
+			expr: "0 0 * * 7",
+			from: utc("2026-01-01 00:00:00"),
+			want: []time.Time{utc("2026-01-04 00:00:00")},
+		},
+		{
+			name: "other locations are matched in UTC",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 3, 8, 1, 30, 0, 0, time.FixedZone("EST", -5*60*60)),
+			want: []time.Time{utc("2026-03-09 02:30:00")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := Parse(test.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.expr, err)
+			}
+			from := test.from
+			for _, want := range test.want {
+				got := schedule.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%s) = %s, want %s", from, got, want)
+				}
+				if !got.IsZero() && got.Location() != time.UTC {
+					t.Errorf("Next(%s) is in %s, want UTC", from, got.Location())
+				}
+				from = got
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package dto
+
+type CreateScheduleDTO struct {
+	FunctionName string `json:"function_name"`
+	Expression   string `json:"expression"`
+	Payload      string `json:"payload"`
+	Enabled      *bool  `json:"enabled"`
+}
+
+type UpdateScheduleDTO struct {
+	Expression *string `json:"expression"`
+	Payload    *string `json:"payload"`
+	Enabled    *bool   `json:"enabled"`
+}
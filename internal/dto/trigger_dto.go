@@ -1,6 +1,20 @@
 package dto
 
+import "time"
+
 type CreateTriggerDTO struct {
 	FunctionName string `json:"function_name"`
 	Payload      string `json:"payload"`
+	// Priority is the priority of the execution. Higher priorities are
+	// dequeued first. Defaults to 0.
+	Priority int `json:"priority"`
+	// RunAt is the time at which the function should run. It is mutually
+	// exclusive with Delay.
+	RunAt *time.Time `json:"run_at"`
+	// Delay is how long to wait before the function runs, as a Go duration
+	// string (e.g. "90s" or "5m"). It is mutually exclusive with RunAt.
+	Delay string `json:"delay"`
+	// IdempotencyKey identifies repeated submissions of the same trigger. The
+	// `Idempotency-Key` header takes precedence over this field.
+	IdempotencyKey string `json:"idempotency_key"`
 }
@@ -17,6 +17,31 @@ const (
 	ErrorCodeDatabase ErrorCode = "DATABASE_ERROR"
 	// ErrorCodeInvalidBody indicates that the request body could not be parsed.
 	ErrorCodeInvalidBody ErrorCode = "INVALID_BODY"
+	// ErrorCodeInvalidQuery indicates that a query parameter of the request
+	// is malformed.
+	ErrorCodeInvalidQuery ErrorCode = "INVALID_QUERY"
+	// ErrorCodeQueue indicates that an error occurred while accessing the
+	// job queue, such as Redis being unavailable.
+	ErrorCodeQueue ErrorCode = "QUEUE_ERROR"
+	// ErrorCodeMalformedJob indicates that a queued job could not be decoded.
+	ErrorCodeMalformedJob ErrorCode = "MALFORMED_JOB"
+	// ErrorCodeExecutionNotCancellable indicates that an execution cannot be
+	// cancelled because it has already finished.
+	ErrorCodeExecutionNotCancellable ErrorCode = "EXECUTION_NOT_CANCELLABLE"
+	// ErrorCodeIdempotencyKeyReused indicates that an idempotency key has
+	// been reused for a request that differs from the original one.
+	ErrorCodeIdempotencyKeyReused ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	// ErrorCodeIdempotencyKeyInUse indicates that the original request with
+	// the same idempotency key has not completed yet.
+	ErrorCodeIdempotencyKeyInUse ErrorCode = "IDEMPOTENCY_KEY_IN_USE"
+	// ErrorCodeNotFound indicates that the requested resource does not exist.
+	ErrorCodeNotFound ErrorCode = "NOT_FOUND"
+	// ErrorCodeInvalidCronExpression indicates that a schedule's CRON
+	// expression could not be parsed.
+	ErrorCodeInvalidCronExpression ErrorCode = "INVALID_CRON_EXPRESSION"
+	// ErrorCodeTriggerActive indicates that a trigger cannot be deleted
+	// because one of its executions is pending or running.
+	ErrorCodeTriggerActive ErrorCode = "TRIGGER_ACTIVE"
 )
 
 // GenericError represents an application error that can be safely serialized
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS schedules (
+  id BLOB(16) PRIMARY KEY,
+  function_name TEXT NOT NULL,
+  expression TEXT NOT NULL,
+  payload TEXT DEFAULT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  next_run_at DATETIME DEFAULT NULL,
+  last_run_at DATETIME DEFAULT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_schedules_next_run_at ON schedules(next_run_at);
+
+ALTER TABLE triggers ADD COLUMN schedule_id BLOB(16) DEFAULT NULL REFERENCES schedules(id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+ALTER TABLE executions ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,20 @@
+-- SQLite cannot alter a check constraint, so the table is rebuilt. The check
+-- allows every status at once, including those introduced by later
+-- migrations, so that it does not need to be rebuilt again for each of them.
+CREATE TABLE executions_new (
+  id BLOB(16) PRIMARY KEY,
+  status NOT NULL CHECK (status in (
+    'PENDING', 'RUNNING', 'COMPLETED', 'FAILED', 'TIMED_OUT', 'CANCELLED', 'SKIPPED', 'DEAD'
+  )),
+  trigger_id BLOB(16) NOT NULL,
+  started_at DATETIME DEFAULT NULL,
+  finished_at DATETIME DEFAULT NULL,
+  attempt INTEGER NOT NULL DEFAULT 1,
+  FOREIGN KEY (trigger_id) REFERENCES triggers(id) ON DELETE CASCADE
+);
+
+INSERT INTO executions_new (id, status, trigger_id, started_at, finished_at, attempt)
+SELECT id, status, trigger_id, started_at, finished_at, attempt FROM executions;
+
+DROP TABLE executions;
+ALTER TABLE executions_new RENAME TO executions;
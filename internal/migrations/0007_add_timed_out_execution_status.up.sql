@@ -0,0 +1 @@
+-- The TIMED_OUT status is allowed by the check of migration 0006.
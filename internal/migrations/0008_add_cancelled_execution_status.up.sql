@@ -0,0 +1 @@
+-- The CANCELLED status is allowed by the check of migration 0006.
@@ -0,0 +1,4 @@
+ALTER TABLE executions ADD COLUMN worker_id TEXT DEFAULT NULL;
+ALTER TABLE executions ADD COLUMN lease_expires_at DATETIME DEFAULT NULL;
+
+CREATE INDEX idx_executions_status_lease ON executions(status, lease_expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE executions ADD COLUMN result TEXT DEFAULT NULL;
+ALTER TABLE executions ADD COLUMN error TEXT DEFAULT NULL;
+ALTER TABLE executions ADD COLUMN stack_trace TEXT DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE executions ADD COLUMN scheduled_at DATETIME DEFAULT NULL;
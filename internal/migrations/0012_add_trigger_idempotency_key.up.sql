@@ -0,0 +1,3 @@
+ALTER TABLE triggers ADD COLUMN idempotency_key TEXT DEFAULT NULL;
+
+CREATE INDEX idx_triggers_idempotency_key ON triggers(idempotency_key, created_at);
@@ -0,0 +1 @@
+-- The SKIPPED status is allowed by the check of migration 0006.
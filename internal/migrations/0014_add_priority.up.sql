@@ -0,0 +1,3 @@
+ALTER TABLE triggers ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE executions ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+ALTER TABLE executions ADD COLUMN queue TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE executions ADD COLUMN queue_score REAL DEFAULT NULL;
+ALTER TABLE executions ADD COLUMN available_at DATETIME DEFAULT NULL;
+ALTER TABLE executions ADD COLUMN claimed_by TEXT DEFAULT NULL;
+ALTER TABLE executions ADD COLUMN claimed_at DATETIME DEFAULT NULL;
+
+CREATE INDEX idx_executions_queue_score ON executions(queue, queue_score) WHERE queue_score IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+  seq INTEGER PRIMARY KEY AUTOINCREMENT,
+  entry TEXT NOT NULL
+);
@@ -0,0 +1,10 @@
+ALTER TABLE executions ADD COLUMN created_at DATETIME DEFAULT NULL;
+
+-- Executions created so far are dated by their trigger. The timestamp is
+-- written in the format used for timestamps set by the application.
+UPDATE executions
+SET created_at = (SELECT t.created_at || '+00:00' FROM triggers t WHERE t.id = executions.trigger_id);
+
+CREATE INDEX idx_executions_created_at ON executions(created_at, id);
+CREATE INDEX idx_executions_status_created_at ON executions(status, created_at, id);
+CREATE INDEX idx_executions_trigger_id_created_at ON executions(trigger_id, created_at);
@@ -0,0 +1 @@
+CREATE INDEX idx_triggers_created_at ON triggers(created_at, id);
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned by `ParseCursor` if the cursor is malformed.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a listing of entities ordered by descending
+// creation time and ID, right after the entity created at `CreatedAt` with
+// the ID `ID`.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// ExecutionCursor returns the position right after the given execution.
+func ExecutionCursor(execution *models.Execution) *Cursor {
+	return newCursor(execution.CreatedAt, execution.ID)
+}
+
+// TriggerCursor returns the position right after the given trigger.
+func TriggerCursor(trigger *models.Trigger) *Cursor {
+	return newCursor(trigger.CreatedAt, *trigger.ID)
+}
+
+// newCursor returns the position right after the entity created at
+// `createdAt` with the given ID. Entities without a creation time are listed
+// as if created at the zero time.
+func newCursor(createdAt *time.Time, id uuid.UUID) *Cursor {
+	cursor := &Cursor{ID: id}
+	if createdAt != nil {
+		cursor.CreatedAt = createdAt.UTC()
+	}
+	return cursor
+}
+
+// String encodes the cursor as an opaque string, which is decoded by
+// `ParseCursor`.
+func (c *Cursor) String() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a cursor encoded by `Cursor.String`.
+func ParseCursor(value string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	createdAtValue, idValue, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtValue)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(idValue)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: createdAt.UTC(), ID: id}, nil
+}
+
+// compareListed compares the position of an entity created at `createdAt`
+// with the given ID in a listing with the given position, returning a
+// positive number if the entity is listed after it.
+func compareListed(createdAt *time.Time, id uuid.UUID, position *Cursor) int {
+	var created time.Time
+	if createdAt != nil {
+		created = *createdAt
+	}
+	if c := position.CreatedAt.Compare(created); c != 0 {
+		return c
+	}
+	return strings.Compare(position.ID.String(), id.String())
+}
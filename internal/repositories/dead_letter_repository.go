@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// DeadLetterRepository handles database operations for the entries of the
+// dead-letter queue, which are stored as opaque strings.
+type DeadLetterRepository struct {
+	db *sqlx.DB
+}
+
+// NewDeadLetterRepository creates a new `DeadLetterRepository` backed by the
+// given `sqlx.DB` instance.
+func NewDeadLetterRepository(db *sqlx.DB) *DeadLetterRepository {
+	return &DeadLetterRepository{db: db}
+}
+
+// Create inserts a new entry into the dead-letter queue.
+func (r *DeadLetterRepository) Create(entry string) error {
+	_, err := r.db.Exec("INSERT INTO dead_letters (entry) VALUES (?)", entry)
+	return err
+}
+
+// ListAll retrieves every entry of the dead-letter queue, newest first.
+func (r *DeadLetterRepository) ListAll() ([]string, error) {
+	var entries []string
+	if err := r.db.Select(&entries, "SELECT entry FROM dead_letters ORDER BY seq DESC"); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Delete removes one occurrence of an entry from the dead-letter queue. It
+// reports whether the entry was found.
+func (r *DeadLetterRepository) Delete(entry string) (bool, error) {
+	query := "DELETE FROM dead_letters WHERE seq = (SELECT seq FROM dead_letters WHERE entry = ? LIMIT 1)"
+	result, err := r.db.Exec(query, entry)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DeleteAll removes every entry from the dead-letter queue.
+func (r *DeadLetterRepository) DeleteAll() error {
+	_, err := r.db.Exec("DELETE FROM dead_letters")
+	return err
+}
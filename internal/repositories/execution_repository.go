@@ -5,101 +5,93 @@ import (
 
 	"github.com/Pelfox/quego/models"
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
 )
 
-// ExecutionRepository handles database operations for `Execution` entities.
-type ExecutionRepository struct {
-	db *sqlx.DB
-}
-
-// NewExecutionRepository creates a new `ExecutionRepository` backed by the
-// given `sqlx.DB` instance.
-func NewExecutionRepository(db *sqlx.DB) *ExecutionRepository {
-	return &ExecutionRepository{db: db}
-}
-
-// Create inserts a new `Execution` record into the database. The
-// provided `Execution` struct must include values for `id`, `status`,
-// and `trigger_id`.
-func (r *ExecutionRepository) Create(data *models.Execution) error {
-	query := "INSERT INTO executions (id, status, trigger_id) VALUES (:id, :status, :trigger_id)"
-	_, err := r.db.NamedExec(query, data)
-	return err
-}
-
-// UpdateStatus updates the status of an `Execution` model in the database. In
-// addition to the status field, it conditionally updates timestamp fields
-// depending on the new status:
-// - `ExecutionStatusRunning`: updates `started_at`.
-// - `ExecutionStatusCompleted` or `ExecutionStatusFailed`: updates `finished_at`.
-func (r *ExecutionRepository) UpdateStatus(id uuid.UUID, newStatus models.ExecutionStatus) error {
-	var (
-		query string
-		args  []any
-	)
-
-	switch newStatus {
-	case models.ExecutionStatusRunning:
-		query = "UPDATE executions SET status = ?, started_at = ? WHERE id = ?"
-		args = []any{newStatus, time.Now(), id}
-	case models.ExecutionStatusCompleted, models.ExecutionStatusFailed:
-		query = "UPDATE executions SET status = ?, finished_at = ? WHERE id = ?"
-		args = []any{newStatus, time.Now(), id}
-	default:
-		query = "UPDATE executions SET status = ? WHERE id = ?"
-		args = []any{newStatus, id}
-	}
-
-	_, err := r.db.Exec(query, args...)
-	return err
-}
-
-// GetByID retrieves an `Execution` model by its unique identifier.
-func (r *ExecutionRepository) GetByID(id uuid.UUID) (*models.Execution, error) {
-	var execution models.Execution
-	query := "SELECT * FROM executions WHERE id = ?"
-	if err := r.db.Get(&execution, query, id); err != nil {
-		return nil, err
-	}
-	return &execution, nil
-}
+// ExecutionRepository stores `Execution` entities. Implementations must be
+// safe for concurrent use: status transitions reported by the methods
+// returning a boolean are atomic, so that concurrent workers and API calls
+// agree on a single transition.
+type ExecutionRepository interface {
+	// Create stores a new `Execution`. The provided `Execution` must include
+	// values for `id`, `status`, `trigger_id` and `attempt`, and may include
+	// `priority` and `scheduled_at`. Its trigger must exist. Its `created_at`
+	// is set by the repository.
+	Create(data *models.Execution) error
+	// UpdateStatus updates the status of an `Execution`. In addition to the
+	// status, it updates `started_at` when moving to `Running`, and
+	// `finished_at` when moving to a terminal status. Its lease is cleared
+	// unless it moves to `Running`.
+	UpdateStatus(id uuid.UUID, newStatus models.ExecutionStatus) error
+	// CompareAndUpdateStatus behaves like `UpdateStatus`, but only updates the
+	// `Execution` if its current status equals `expected`. It reports whether
+	// the execution was updated.
+	CompareAndUpdateStatus(id uuid.UUID, expected models.ExecutionStatus, newStatus models.ExecutionStatus) (bool, error)
+	// SaveOutcome stores the outcome of the latest attempt of an `Execution`.
+	// Values that are nil are cleared.
+	SaveOutcome(id uuid.UUID, result *types.JSONText, errorMessage *string, stackTrace *string) error
+	// ScheduleRetry moves a failed `Execution` back to the `Pending` state and
+	// records the number of the attempt it is waiting for. It reports whether
+	// the execution exists.
+	ScheduleRetry(id uuid.UUID, attempt int) (bool, error)
+	// Acquire moves a pending `Execution` to the `Running` state and grants
+	// the given worker a lease on it until `leaseExpiresAt`. It reports
+	// whether the execution was acquired.
+	Acquire(id uuid.UUID, workerID string, leaseExpiresAt time.Time) (bool, error)
+	// RenewLease extends the lease a worker holds on a running `Execution`
+	// until `leaseExpiresAt`. It reports whether the lease was renewed.
+	RenewLease(id uuid.UUID, workerID string, leaseExpiresAt time.Time) (bool, error)
+	// ReleaseExpiredLease moves a running `Execution` whose lease has expired
+	// at `now` back to the `Pending` state. It reports whether the execution
+	// was released.
+	ReleaseExpiredLease(id uuid.UUID, now time.Time) (bool, error)
 
-// ListAll retrieves all `Execution` records from the database, ordered by
-// start time in descending order.
-func (r *ExecutionRepository) ListAll() ([]*models.ExecutionWithTrigger, error) {
-	var executions []*models.ExecutionWithTrigger
-	query := `
-	SELECT
-		e.*,
-		t.id AS "trigger.id",
-		t.trigger_type AS "trigger.trigger_type",
-		t.function_name AS "trigger.function_name"
-	FROM executions e
-	JOIN triggers t ON e.trigger_id = t.id
-	ORDER BY e.started_at DESC
-	`
-	if err := r.db.Select(&executions, query); err != nil {
-		return nil, err
-	}
-	return executions, nil
+	// GetByID retrieves an `Execution` by its unique identifier. It returns
+	// nil without an error if no such execution exists.
+	GetByID(id uuid.UUID) (*models.Execution, error)
+	// GetLatestByTriggerID retrieves the most recently created `Execution` of
+	// the given trigger. It returns nil without an error if the trigger has
+	// no executions.
+	GetLatestByTriggerID(triggerID uuid.UUID) (*models.Execution, error)
+	// List retrieves the executions matching the filter along with the ID,
+	// type and function name of their trigger, newest first. Executions
+	// created at the same time are ordered by descending ID.
+	List(filter ExecutionFilter) ([]*models.ExecutionWithTrigger, error)
+	// ListExpiredLeases retrieves every running `Execution` whose lease has
+	// expired at `now` or that has no lease, along with the ID, type,
+	// function name, payload and priority of its trigger.
+	ListExpiredLeases(now time.Time) ([]*models.ExecutionWithTrigger, error)
+	// ListPending retrieves every pending `Execution` created before
+	// `createdBefore`, along with the ID, type, function name, payload and
+	// priority of its trigger, oldest first.
+	ListPending(createdBefore time.Time) ([]*models.ExecutionWithTrigger, error)
+	// CountByFunction counts the executions in each of the given statuses,
+	// keyed by the function name of their trigger. Functions without such
+	// executions are omitted.
+	CountByFunction(statuses []models.ExecutionStatus) (map[string]map[models.ExecutionStatus]int, error)
 }
 
-func (r *ExecutionRepository) GetStaled() ([]*models.ExecutionWithTrigger, error) {
-	var stales []*models.ExecutionWithTrigger
-	query := `
-	SELECT
-		e.*,
-		t.id AS "trigger.id",
-		t.trigger_type AS "trigger.trigger_type",
-		t.function_name AS "trigger.function_name",
-		t.payload AS "trigger.payload"
-	FROM executions e
-	JOIN triggers t ON e.trigger_id = t.id
-	WHERE e.status != ? AND e.status != ?
-	`
-	if err := r.db.Select(&stales, query, models.ExecutionStatusCompleted, models.ExecutionStatusFailed); err != nil {
-		return nil, err
-	}
-	return stales, nil
+// ExecutionFilter selects the executions retrieved by
+// `ExecutionRepository.List`. Zero values select every execution.
+type ExecutionFilter struct {
+	// Statuses restricts the executions to the given statuses.
+	Statuses []models.ExecutionStatus
+	// FunctionName restricts the executions to those of the given function.
+	FunctionName string
+	// TriggerType restricts the executions to those of triggers of the given
+	// type.
+	TriggerType models.TriggerType
+	// TriggerID restricts the executions to those of the given trigger.
+	TriggerID *uuid.UUID
+	// CreatedAfter restricts the executions to those created at or after the
+	// given time.
+	CreatedAfter *time.Time
+	// CreatedBefore restricts the executions to those created before the
+	// given time.
+	CreatedBefore *time.Time
+	// After restricts the executions to those listed after the given
+	// position, in order to retrieve the next page of a listing.
+	After *Cursor
+	// Limit is the maximum number of executions to retrieve, if positive.
+	Limit int
 }
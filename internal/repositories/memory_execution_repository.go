@@ -0,0 +1,324 @@
+package repositories
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+)
+
+// MemoryExecutionRepository is an `ExecutionRepository` over the executions
+// of a `MemoryStore`.
+type MemoryExecutionRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a copy of the `Execution`, provided its trigger exists, and
+// sets its `created_at` to the current time.
+func (r *MemoryExecutionRepository) Create(data *models.Execution) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.create(data)
+}
+
+// UpdateStatus updates the status of an `Execution`, along with its
+// timestamps and lease.
+func (r *MemoryExecutionRepository) UpdateStatus(id uuid.UUID, newStatus models.ExecutionStatus) error {
+	r.update(id, nil, func(execution *models.Execution) bool {
+		setStatus(execution, newStatus)
+		return true
+	})
+	return nil
+}
+
+// CompareAndUpdateStatus behaves like `UpdateStatus`, but only updates the
+// `Execution` if its current status equals `expected`.
+func (r *MemoryExecutionRepository) CompareAndUpdateStatus(
+	id uuid.UUID,
+	expected models.ExecutionStatus,
+	newStatus models.ExecutionStatus,
+) (bool, error) {
+	return r.update(id, &expected, func(execution *models.Execution) bool {
+		setStatus(execution, newStatus)
+		return true
+	}), nil
+}
+
+// SaveOutcome stores the outcome of the latest attempt of an `Execution`.
+func (r *MemoryExecutionRepository) SaveOutcome(
+	id uuid.UUID,
+	result *types.JSONText,
+	errorMessage *string,
+	stackTrace *string,
+) error {
+	r.update(id, nil, func(execution *models.Execution) bool {
+		execution.Result = result
+		execution.Error = errorMessage
+		execution.StackTrace = stackTrace
+		return true
+	})
+	return nil
+}
+
+// ScheduleRetry moves a failed `Execution` back to the `Pending` state and
+// records the number of the attempt it is waiting for.
+func (r *MemoryExecutionRepository) ScheduleRetry(id uuid.UUID, attempt int) (bool, error) {
+	return r.update(id, nil, func(execution *models.Execution) bool {
+		execution.Status = models.ExecutionStatusPending
+		execution.Attempt = attempt
+		execution.WorkerID = nil
+		execution.LeaseExpiresAt = nil
+		return true
+	}), nil
+}
+
+// Acquire moves a pending `Execution` to the `Running` state and grants the
+// given worker a lease on it until `leaseExpiresAt`.
+func (r *MemoryExecutionRepository) Acquire(id uuid.UUID, workerID string, leaseExpiresAt time.Time) (bool, error) {
+	expected := models.ExecutionStatusPending
+	return r.update(id, &expected, func(execution *models.Execution) bool {
+		startedAt := time.Now()
+		execution.Status = models.ExecutionStatusRunning
+		execution.StartedAt = &startedAt
+		execution.WorkerID = &workerID
+		execution.LeaseExpiresAt = &leaseExpiresAt
+		return true
+	}), nil
+}
+
+// RenewLease extends the lease a worker holds on a running `Execution` until
+// `leaseExpiresAt`.
+func (r *MemoryExecutionRepository) RenewLease(id uuid.UUID, workerID string, leaseExpiresAt time.Time) (bool, error) {
+	expected := models.ExecutionStatusRunning
+	return r.update(id, &expected, func(execution *models.Execution) bool {
+		if execution.WorkerID == nil || *execution.WorkerID != workerID {
+			return false
+		}
+		execution.LeaseExpiresAt = &leaseExpiresAt
+		return true
+	}), nil
+}
+
+// ReleaseExpiredLease moves a running `Execution` whose lease has expired at
+// `now` back to the `Pending` state.
+func (r *MemoryExecutionRepository) ReleaseExpiredLease(id uuid.UUID, now time.Time) (bool, error) {
+	expected := models.ExecutionStatusRunning
+	return r.update(id, &expected, func(execution *models.Execution) bool {
+		if !leaseExpired(execution, now) {
+			return false
+		}
+		execution.Status = models.ExecutionStatusPending
+		execution.WorkerID = nil
+		execution.LeaseExpiresAt = nil
+		return true
+	}), nil
+}
+
+// GetByID retrieves a copy of an `Execution` by its unique identifier.
+func (r *MemoryExecutionRepository) GetByID(id uuid.UUID) (*models.Execution, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	execution, ok := r.store.executions[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *execution
+	return &found, nil
+}
+
+// GetLatestByTriggerID retrieves a copy of the most recently created
+// `Execution` of the given trigger.
+func (r *MemoryExecutionRepository) GetLatestByTriggerID(triggerID uuid.UUID) (*models.Execution, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := len(r.store.executionOrder) - 1; i >= 0; i-- {
+		execution := r.store.executions[r.store.executionOrder[i]]
+		if execution.TriggerID == triggerID {
+			found := *execution
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+// List retrieves copies of the executions matching the filter, newest
+// first, along with a summary of their trigger.
+func (r *MemoryExecutionRepository) List(filter ExecutionFilter) ([]*models.ExecutionWithTrigger, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	executions := r.withTriggers(func(execution *models.Execution) bool {
+		trigger := r.store.triggers[execution.TriggerID]
+		createdAt := execution.CreatedAt
+		switch {
+		case len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, execution.Status):
+			return false
+		case filter.FunctionName != "" && trigger.FunctionName != filter.FunctionName:
+			return false
+		case filter.TriggerType != "" && trigger.TriggerType != filter.TriggerType:
+			return false
+		case filter.TriggerID != nil && execution.TriggerID != *filter.TriggerID:
+			return false
+		case filter.CreatedAfter != nil && createdAt.Before(*filter.CreatedAfter):
+			return false
+		case filter.CreatedBefore != nil && !createdAt.Before(*filter.CreatedBefore):
+			return false
+		case filter.After != nil && compareListed(execution.CreatedAt, execution.ID, filter.After) <= 0:
+			return false
+		}
+		return true
+	}, false)
+
+	slices.SortFunc(executions, func(a, b *models.ExecutionWithTrigger) int {
+		return compareListed(a.CreatedAt, a.ID, ExecutionCursor(&b.Execution))
+	})
+	if filter.Limit > 0 && len(executions) > filter.Limit {
+		executions = executions[:filter.Limit]
+	}
+	return executions, nil
+}
+
+// ListExpiredLeases retrieves copies of every running `Execution` whose lease
+// has expired at `now`, along with its trigger.
+func (r *MemoryExecutionRepository) ListExpiredLeases(now time.Time) ([]*models.ExecutionWithTrigger, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.withTriggers(func(execution *models.Execution) bool {
+		return execution.Status == models.ExecutionStatusRunning && leaseExpired(execution, now)
+	}, true), nil
+}
+
+// ListPending retrieves copies of every pending `Execution` created before
+// `createdBefore`, along with its trigger, oldest first.
+func (r *MemoryExecutionRepository) ListPending(createdBefore time.Time) ([]*models.ExecutionWithTrigger, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.withTriggers(func(execution *models.Execution) bool {
+		return execution.Status == models.ExecutionStatusPending &&
+			execution.CreatedAt != nil && execution.CreatedAt.Before(createdBefore)
+	}, true), nil
+}
+
+// CountByFunction counts the executions in each of the given statuses, keyed
+// by the function name of their trigger.
+func (r *MemoryExecutionRepository) CountByFunction(
+	statuses []models.ExecutionStatus,
+) (map[string]map[models.ExecutionStatus]int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	counts := make(map[string]map[models.ExecutionStatus]int)
+	for _, execution := range r.store.executions {
+		if !slices.Contains(statuses, execution.Status) {
+			continue
+		}
+		functionName := r.store.triggers[execution.TriggerID].FunctionName
+		if counts[functionName] == nil {
+			counts[functionName] = make(map[models.ExecutionStatus]int)
+		}
+		counts[functionName][execution.Status]++
+	}
+	return counts, nil
+}
+
+// create stores a copy of the `Execution`, provided its trigger exists, and
+// sets its `created_at` to the current time. The caller must hold the lock of
+// the store.
+func (r *MemoryExecutionRepository) create(data *models.Execution) error {
+	if _, ok := r.store.executions[data.ID]; ok {
+		return fmt.Errorf("execution %s already exists", data.ID)
+	}
+	if _, ok := r.store.triggers[data.TriggerID]; !ok {
+		return fmt.Errorf("trigger %s does not exist", data.TriggerID)
+	}
+
+	createdAt := time.Now().UTC()
+	data.CreatedAt = &createdAt
+	r.store.executions[data.ID] = &models.Execution{
+		ID:          data.ID,
+		Status:      data.Status,
+		TriggerID:   data.TriggerID,
+		Attempt:     data.Attempt,
+		Priority:    data.Priority,
+		CreatedAt:   &createdAt,
+		ScheduledAt: data.ScheduledAt,
+		Queue:       models.DefaultQueue,
+	}
+	r.store.executionOrder = append(r.store.executionOrder, data.ID)
+	return nil
+}
+
+// update applies `apply` to the `Execution` with the given ID, provided it
+// exists and, if `expected` is not nil, is in that status. It reports whether
+// the execution was updated, which is not the case if `apply` returns false.
+func (r *MemoryExecutionRepository) update(
+	id uuid.UUID,
+	expected *models.ExecutionStatus,
+	apply func(execution *models.Execution) bool,
+) bool {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	execution, ok := r.store.executions[id]
+	if !ok || (expected != nil && execution.Status != *expected) {
+		return false
+	}
+	updated := *execution
+	if !apply(&updated) {
+		return false
+	}
+	r.store.executions[id] = &updated
+	return true
+}
+
+// withTriggers returns copies of the executions matching `match` in creation
+// order, along with the ID, type and function name of their trigger. If
+// `details` is set, the payload and priority of the trigger are included as
+// well. The caller must hold the lock of the store.
+func (r *MemoryExecutionRepository) withTriggers(
+	match func(execution *models.Execution) bool,
+	details bool,
+) []*models.ExecutionWithTrigger {
+	var executions []*models.ExecutionWithTrigger
+	for _, id := range r.store.executionOrder {
+		execution := r.store.executions[id]
+		if !match(execution) {
+			continue
+		}
+		trigger := r.store.triggers[execution.TriggerID]
+		summary := models.Trigger{
+			ID:           trigger.ID,
+			TriggerType:  trigger.TriggerType,
+			FunctionName: trigger.FunctionName,
+		}
+		if details {
+			summary.Payload = trigger.Payload
+			summary.Priority = trigger.Priority
+		}
+		executions = append(executions, &models.ExecutionWithTrigger{Execution: *execution, Trigger: summary})
+	}
+	return executions
+}
+
+// setStatus updates the status of an `Execution` like `UpdateStatus`.
+func setStatus(execution *models.Execution, newStatus models.ExecutionStatus) {
+	now := time.Now()
+	execution.Status = newStatus
+	switch {
+	case newStatus == models.ExecutionStatusRunning:
+		execution.StartedAt = &now
+	case newStatus.IsTerminal():
+		execution.FinishedAt = &now
+	}
+	// Only running executions hold a lease.
+	if newStatus != models.ExecutionStatusRunning {
+		execution.WorkerID = nil
+		execution.LeaseExpiresAt = nil
+	}
+}
+
+// leaseExpired reports whether the lease on an `Execution` has expired at
+// `now`. Executions without a lease are considered expired.
+func leaseExpired(execution *models.Execution, now time.Time) bool {
+	return execution.LeaseExpiresAt == nil || execution.LeaseExpiresAt.Before(now)
+}
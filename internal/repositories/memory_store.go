@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// MemoryStore holds `Trigger` and `Execution` entities in the memory of the
+// process, and hands out repositories over them. It is meant for tests and
+// for embedding the services without a database: its entities are lost when
+// the process exits.
+//
+// Like the SQLite schema, it only accepts executions of existing triggers.
+// Entities are copied on the way in and out, so that callers cannot modify
+// the stored ones.
+type MemoryStore struct {
+	mu sync.Mutex
+	// triggers holds the triggers by ID.
+	triggers map[uuid.UUID]*models.Trigger
+	// triggerOrder holds the IDs of the triggers in creation order.
+	triggerOrder []uuid.UUID
+	// executions holds the executions by ID.
+	executions map[uuid.UUID]*models.Execution
+	// executionOrder holds the IDs of the executions in creation order.
+	executionOrder []uuid.UUID
+}
+
+// NewMemoryStore creates a new, empty `MemoryStore`.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		triggers:   make(map[uuid.UUID]*models.Trigger),
+		executions: make(map[uuid.UUID]*models.Execution),
+	}
+}
+
+// Triggers returns a `TriggerRepository` over the triggers of the store.
+func (s *MemoryStore) Triggers() *MemoryTriggerRepository {
+	return &MemoryTriggerRepository{store: s}
+}
+
+// Executions returns an `ExecutionRepository` over the executions of the
+// store.
+func (s *MemoryStore) Executions() *MemoryExecutionRepository {
+	return &MemoryExecutionRepository{store: s}
+}
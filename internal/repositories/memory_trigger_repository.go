@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// MemoryTriggerRepository is a `TriggerRepository` over the triggers of a
+// `MemoryStore`.
+type MemoryTriggerRepository struct {
+	store *MemoryStore
+}
+
+// Create stores a copy of the `Trigger`.
+func (r *MemoryTriggerRepository) Create(data *models.Trigger) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.create(data)
+}
+
+// CreateWithExecution stores copies of the `Trigger` and of its first
+// `Execution`, unless the trigger carries an idempotency key that another
+// trigger has used within the given window.
+func (r *MemoryTriggerRepository) CreateWithExecution(
+	data *models.Trigger,
+	execution *models.Execution,
+	window time.Duration,
+) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if data.IdempotencyKey != nil && r.getByIdempotencyKey(*data.IdempotencyKey, window) != nil {
+		return false, nil
+	}
+	if err := r.create(data); err != nil {
+		return false, err
+	}
+	executions := MemoryExecutionRepository{store: r.store}
+	if err := executions.create(execution); err != nil {
+		// Roll back the trigger, as a transaction would.
+		r.delete(*data.ID)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByIdempotencyKey retrieves the most recent `Trigger` with the given
+// idempotency key that has been created within the given window.
+func (r *MemoryTriggerRepository) GetByIdempotencyKey(key string, window time.Duration) (*models.Trigger, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	trigger := r.getByIdempotencyKey(key, window)
+	if trigger == nil {
+		return nil, nil
+	}
+	found := *trigger
+	return &found, nil
+}
+
+// GetByID retrieves a copy of a `Trigger` by its unique identifier.
+func (r *MemoryTriggerRepository) GetByID(id uuid.UUID) (*models.Trigger, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	trigger, ok := r.store.triggers[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *trigger
+	return &found, nil
+}
+
+// List retrieves copies of the triggers matching the filter, newest first.
+func (r *MemoryTriggerRepository) List(filter TriggerFilter) ([]*models.Trigger, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var triggers []*models.Trigger
+	for _, id := range r.store.triggerOrder {
+		trigger := r.store.triggers[id]
+		switch {
+		case filter.FunctionName != "" && trigger.FunctionName != filter.FunctionName:
+			continue
+		case filter.TriggerType != "" && trigger.TriggerType != filter.TriggerType:
+			continue
+		case filter.After != nil && compareListed(trigger.CreatedAt, id, filter.After) <= 0:
+			continue
+		}
+		found := *trigger
+		triggers = append(triggers, &found)
+	}
+
+	slices.SortFunc(triggers, func(a, b *models.Trigger) int {
+		return compareListed(a.CreatedAt, *a.ID, TriggerCursor(b))
+	})
+	if filter.Limit > 0 && len(triggers) > filter.Limit {
+		triggers = triggers[:filter.Limit]
+	}
+	return triggers, nil
+}
+
+// DeleteInactive deletes a `Trigger` along with its executions, unless one of
+// them is pending or running.
+func (r *MemoryTriggerRepository) DeleteInactive(id uuid.UUID) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if _, ok := r.store.triggers[id]; !ok {
+		return false, nil
+	}
+	for _, execution := range r.store.executions {
+		if execution.TriggerID == id && !execution.Status.IsTerminal() {
+			return false, nil
+		}
+	}
+
+	r.delete(id)
+	return true, nil
+}
+
+// Delete deletes a `Trigger` along with its executions.
+func (r *MemoryTriggerRepository) Delete(id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.delete(id)
+	return nil
+}
+
+// create stores a copy of the `Trigger`, along with its creation time. The
+// caller must hold the lock of the store.
+func (r *MemoryTriggerRepository) create(data *models.Trigger) error {
+	if data.ID == nil {
+		return fmt.Errorf("trigger has no ID")
+	}
+	if _, ok := r.store.triggers[*data.ID]; ok {
+		return fmt.Errorf("trigger %s already exists", *data.ID)
+	}
+
+	createdAt := time.Now().UTC()
+	trigger := models.Trigger{
+		ID:             data.ID,
+		TriggerType:    data.TriggerType,
+		FunctionName:   data.FunctionName,
+		Payload:        data.Payload,
+		Priority:       data.Priority,
+		ScheduleID:     data.ScheduleID,
+		IdempotencyKey: data.IdempotencyKey,
+		CreatedAt:      &createdAt,
+	}
+	r.store.triggers[*data.ID] = &trigger
+	r.store.triggerOrder = append(r.store.triggerOrder, *data.ID)
+	return nil
+}
+
+// delete deletes a `Trigger` along with its executions, if it exists. The
+// caller must hold the lock of the store.
+func (r *MemoryTriggerRepository) delete(id uuid.UUID) {
+	if _, ok := r.store.triggers[id]; !ok {
+		return
+	}
+	delete(r.store.triggers, id)
+	r.store.triggerOrder = slices.DeleteFunc(r.store.triggerOrder, func(triggerID uuid.UUID) bool {
+		return triggerID == id
+	})
+	r.store.executionOrder = slices.DeleteFunc(r.store.executionOrder, func(executionID uuid.UUID) bool {
+		if r.store.executions[executionID].TriggerID != id {
+			return false
+		}
+		delete(r.store.executions, executionID)
+		return true
+	})
+}
+
+// getByIdempotencyKey returns the most recent stored `Trigger` with the given
+// idempotency key that has been created within the given window, or nil. The
+// caller must hold the lock of the store.
+func (r *MemoryTriggerRepository) getByIdempotencyKey(key string, window time.Duration) *models.Trigger {
+	since := time.Now().Add(-window)
+	for i := len(r.store.triggerOrder) - 1; i >= 0; i-- {
+		trigger := r.store.triggers[r.store.triggerOrder[i]]
+		if trigger.IdempotencyKey != nil && *trigger.IdempotencyKey == key && !trigger.CreatedAt.Before(since) {
+			return trigger
+		}
+	}
+	return nil
+}
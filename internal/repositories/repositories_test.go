@@ -0,0 +1,38 @@
+package repositories_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/Pelfox/quego/internal"
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/internal/repositories/repotest"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestMemoryRepositories(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Store {
+		store := repositories.NewMemoryStore()
+		return repotest.Store{Executions: store.Executions(), Triggers: store.Triggers()}
+	})
+}
+
+func TestSQLiteRepositories(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Store {
+		path := filepath.Join(t.TempDir(), "quego.db")
+		if err := internal.MigrateDatabase(path); err != nil {
+			t.Fatal(err)
+		}
+		db, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return repotest.Store{
+			Executions: repositories.NewSQLiteExecutionRepository(db),
+			Triggers:   repositories.NewSQLiteTriggerRepository(db),
+		}
+	})
+}
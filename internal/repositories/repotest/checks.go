@@ -0,0 +1,856 @@
+package repotest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+)
+
+// window is the idempotency window used by the checks.
+const window = time.Hour
+
+// newTrigger creates a trigger of the given function with a payload and a
+// priority.
+func newTrigger(t *testing.T, store Store, functionName string) *models.Trigger {
+	t.Helper()
+	id := uuid.New()
+	trigger := &models.Trigger{
+		ID:           &id,
+		TriggerType:  models.TriggerTypeEvent,
+		FunctionName: functionName,
+		Payload:      `{"answer":42}`,
+		Priority:     3,
+	}
+	if err := store.Triggers.Create(trigger); err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+	return trigger
+}
+
+// newExecution creates a pending execution of the given trigger.
+func newExecution(t *testing.T, store Store, trigger *models.Trigger) *models.Execution {
+	t.Helper()
+	execution := &models.Execution{
+		ID:        uuid.New(),
+		Status:    models.ExecutionStatusPending,
+		TriggerID: *trigger.ID,
+		Attempt:   1,
+		Priority:  trigger.Priority,
+	}
+	if err := store.Executions.Create(execution); err != nil {
+		t.Fatalf("failed to create execution: %v", err)
+	}
+	return execution
+}
+
+// getExecution retrieves an execution that must exist.
+func getExecution(t *testing.T, store Store, id uuid.UUID) *models.Execution {
+	t.Helper()
+	execution, err := store.Executions.GetByID(id)
+	if err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if execution == nil {
+		t.Fatalf("execution %s not found", id)
+	}
+	return execution
+}
+
+// pendingExecution returns a pending execution of the given trigger, which is
+// not stored yet.
+func pendingExecution(trigger *models.Trigger) *models.Execution {
+	return &models.Execution{
+		ID:        uuid.New(),
+		Status:    models.ExecutionStatusPending,
+		TriggerID: *trigger.ID,
+		Attempt:   1,
+	}
+}
+
+// checkIdempotencyKeys checks that a trigger is only created once per
+// idempotency key, along with its execution, and can be looked up by its key.
+func checkIdempotencyKeys(t *testing.T, store Store) {
+	key := "key-" + uuid.NewString()
+	first, second := uuid.New(), uuid.New()
+	trigger := &models.Trigger{
+		ID:             &first,
+		TriggerType:    models.TriggerTypeEvent,
+		FunctionName:   "fn",
+		IdempotencyKey: &key,
+	}
+	execution := pendingExecution(trigger)
+	created, err := store.Triggers.CreateWithExecution(trigger, execution, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatalf("trigger with an unused key was not created")
+	}
+	if execution.CreatedAt == nil {
+		t.Fatalf("created execution has no creation time")
+	}
+	getExecution(t, store, execution.ID)
+
+	repeated := *trigger
+	repeated.ID = &second
+	skipped := pendingExecution(&repeated)
+	created, err = store.Triggers.CreateWithExecution(&repeated, skipped, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatalf("trigger with a used key was created")
+	}
+	if found, err := store.Executions.GetByID(skipped.ID); err != nil || found != nil {
+		t.Fatalf("got execution %v and error %v for a trigger that was not created", found, err)
+	}
+
+	found, err := store.Triggers.GetByIdempotencyKey(key, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID == nil || *found.ID != first {
+		t.Fatalf("got trigger %v for the key, want %s", found, first)
+	}
+	if found.CreatedAt == nil {
+		t.Fatalf("trigger has no creation time")
+	}
+
+	missing, err := store.Triggers.GetByIdempotencyKey("key-"+uuid.NewString(), window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != nil {
+		t.Fatalf("got trigger %s for an unused key", *missing.ID)
+	}
+
+	for range 2 {
+		id := uuid.New()
+		trigger := &models.Trigger{ID: &id, TriggerType: models.TriggerTypeEvent, FunctionName: "fn"}
+		created, err := store.Triggers.CreateWithExecution(trigger, pendingExecution(trigger), window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !created {
+			t.Fatalf("trigger without a key was not created")
+		}
+	}
+}
+
+// checkTriggerCreationAtomicity checks that a trigger is not stored if its
+// execution cannot be stored.
+func checkTriggerCreationAtomicity(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "existing")
+	existing := newExecution(t, store, trigger)
+
+	key := "key-" + uuid.NewString()
+	id := uuid.New()
+	failed := &models.Trigger{ID: &id, TriggerType: models.TriggerTypeEvent, FunctionName: "fn", IdempotencyKey: &key}
+	duplicate := pendingExecution(failed)
+	duplicate.ID = existing.ID
+	if _, err := store.Triggers.CreateWithExecution(failed, duplicate, window); err == nil {
+		t.Fatalf("created trigger along with an execution whose ID is taken")
+	}
+	if found, err := store.Triggers.GetByID(id); err != nil || found != nil {
+		t.Fatalf("got trigger %v and error %v after its execution failed to be stored", found, err)
+	}
+
+	// The key of the failed trigger must remain usable.
+	created, err := store.Triggers.CreateWithExecution(failed, pendingExecution(failed), window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatalf("key of a trigger that failed to be stored cannot be used")
+	}
+}
+
+// checkExecutionCreation checks that executions are stored as created, and
+// only for existing triggers.
+func checkExecutionCreation(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "fn")
+	scheduledAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	execution := &models.Execution{
+		ID:          uuid.New(),
+		Status:      models.ExecutionStatusPending,
+		TriggerID:   *trigger.ID,
+		Attempt:     1,
+		Priority:    trigger.Priority,
+		ScheduledAt: &scheduledAt,
+	}
+	if err := store.Executions.Create(execution); err != nil {
+		t.Fatalf("failed to create execution: %v", err)
+	}
+
+	found := getExecution(t, store, execution.ID)
+	switch {
+	case found.Status != execution.Status:
+		t.Fatalf("got status %s, want %s", found.Status, execution.Status)
+	case found.TriggerID != execution.TriggerID:
+		t.Fatalf("got trigger %s, want %s", found.TriggerID, execution.TriggerID)
+	case found.Attempt != 1 || found.Priority != trigger.Priority:
+		t.Fatalf("got attempt %d and priority %d, want 1 and %d", found.Attempt, found.Priority, trigger.Priority)
+	case found.ScheduledAt == nil || !found.ScheduledAt.Equal(scheduledAt):
+		t.Fatalf("got scheduled time %v, want %v", found.ScheduledAt, scheduledAt)
+	case found.StartedAt != nil || found.FinishedAt != nil:
+		t.Fatalf("pending execution has start or finish times")
+	}
+
+	missing, err := store.Executions.GetByID(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != nil {
+		t.Fatalf("got execution %s for an unknown ID", missing.ID)
+	}
+
+	orphan := &models.Execution{
+		ID:        uuid.New(),
+		Status:    models.ExecutionStatusPending,
+		TriggerID: uuid.New(),
+		Attempt:   1,
+	}
+	if err := store.Executions.Create(orphan); err == nil {
+		t.Fatalf("execution of an unknown trigger was created")
+	}
+	if err := store.Executions.Create(execution); err == nil {
+		t.Fatalf("execution with a used ID was created")
+	}
+}
+
+// checkStatusTransitions checks that status updates set the timestamps, and
+// that conditional updates only apply to the expected status.
+func checkStatusTransitions(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "fn")
+	execution := newExecution(t, store, trigger)
+
+	updated, err := store.Executions.CompareAndUpdateStatus(
+		execution.ID,
+		models.ExecutionStatusRunning,
+		models.ExecutionStatusCompleted,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Fatalf("execution was updated from a status it is not in")
+	}
+
+	if err := store.Executions.UpdateStatus(execution.ID, models.ExecutionStatusRunning); err != nil {
+		t.Fatal(err)
+	}
+	found := getExecution(t, store, execution.ID)
+	if found.Status != models.ExecutionStatusRunning || found.StartedAt == nil {
+		t.Fatalf("got status %s and start time %v after starting", found.Status, found.StartedAt)
+	}
+
+	updated, err = store.Executions.CompareAndUpdateStatus(
+		execution.ID,
+		models.ExecutionStatusRunning,
+		models.ExecutionStatusCompleted,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated {
+		t.Fatalf("execution was not updated from its status")
+	}
+	found = getExecution(t, store, execution.ID)
+	if found.Status != models.ExecutionStatusCompleted || found.FinishedAt == nil {
+		t.Fatalf("got status %s and finish time %v after completing", found.Status, found.FinishedAt)
+	}
+
+	updated, err = store.Executions.CompareAndUpdateStatus(
+		uuid.New(),
+		models.ExecutionStatusPending,
+		models.ExecutionStatusCancelled,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Fatalf("unknown execution was updated")
+	}
+}
+
+// checkLeases checks that an execution is acquired once, that its lease is
+// only renewed by its worker, and that it is released once expired.
+func checkLeases(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "fn")
+	execution := newExecution(t, store, trigger)
+
+	now := time.Now()
+	leaseExpiresAt := now.Add(time.Minute).UTC()
+	acquired, err := store.Executions.Acquire(execution.ID, "worker-1", leaseExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acquired {
+		t.Fatalf("pending execution was not acquired")
+	}
+	acquired, err = store.Executions.Acquire(execution.ID, "worker-2", leaseExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acquired {
+		t.Fatalf("running execution was acquired again")
+	}
+
+	found := getExecution(t, store, execution.ID)
+	if found.Status != models.ExecutionStatusRunning || found.StartedAt == nil ||
+		found.WorkerID == nil || *found.WorkerID != "worker-1" {
+		t.Fatalf("acquired execution is not running on its worker")
+	}
+
+	renewed, err := store.Executions.RenewLease(execution.ID, "worker-2", leaseExpiresAt.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed {
+		t.Fatalf("lease was renewed by another worker")
+	}
+	leaseExpiresAt = leaseExpiresAt.Add(time.Minute)
+	renewed, err = store.Executions.RenewLease(execution.ID, "worker-1", leaseExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed {
+		t.Fatalf("lease was not renewed by its worker")
+	}
+
+	expired, err := store.Executions.ListExpiredLeases(now.UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 0 {
+		t.Fatalf("got %d expired leases before expiry, want 0", len(expired))
+	}
+	released, err := store.Executions.ReleaseExpiredLease(execution.ID, now.UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released {
+		t.Fatalf("lease was released before expiry")
+	}
+
+	later := leaseExpiresAt.Add(time.Second)
+	expired, err = store.Executions.ListExpiredLeases(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].ID != execution.ID {
+		t.Fatalf("got %d expired leases after expiry, want the execution", len(expired))
+	}
+	if got := expired[0].Trigger; got.FunctionName != trigger.FunctionName ||
+		got.Payload != trigger.Payload || got.Priority != trigger.Priority {
+		t.Fatalf("got trigger %+v along with the expired lease, want %+v", got, *trigger)
+	}
+
+	released, err = store.Executions.ReleaseExpiredLease(execution.ID, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Fatalf("expired lease was not released")
+	}
+	found = getExecution(t, store, execution.ID)
+	if found.Status != models.ExecutionStatusPending || found.WorkerID != nil || found.LeaseExpiresAt != nil {
+		t.Fatalf("released execution is not pending without a lease")
+	}
+	released, err = store.Executions.ReleaseExpiredLease(execution.ID, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released {
+		t.Fatalf("lease was released twice")
+	}
+
+	// Running executions without a lease are considered expired.
+	if err := store.Executions.UpdateStatus(execution.ID, models.ExecutionStatusRunning); err != nil {
+		t.Fatal(err)
+	}
+	expired, err = store.Executions.ListExpiredLeases(now.UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 {
+		t.Fatalf("got %d expired leases for a running execution without a lease, want 1", len(expired))
+	}
+}
+
+// checkPendingListing checks that pending executions are listed along with
+// the details of their trigger, restricted to those created before the given
+// time.
+func checkPendingListing(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "fn")
+	pending := newExecution(t, store, trigger)
+	completed := newExecution(t, store, trigger)
+	if err := store.Executions.UpdateStatus(completed.ID, models.ExecutionStatusCompleted); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := store.Executions.ListPending(time.Now().Add(time.Second).UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Execution.ID != pending.ID {
+		t.Fatalf("got %d pending executions, want only %s", len(listed), pending.ID)
+	}
+	if listed[0].Trigger.FunctionName != trigger.FunctionName ||
+		listed[0].Trigger.Payload != trigger.Payload ||
+		listed[0].Trigger.Priority != trigger.Priority {
+		t.Fatalf("got trigger %+v, want %+v", listed[0].Trigger, *trigger)
+	}
+
+	listed, err = store.Executions.ListPending(pending.CreatedAt.Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 0 {
+		t.Fatalf("got %d pending executions created before the first one", len(listed))
+	}
+}
+
+// checkOutcomes checks that outcomes are stored and cleared, and that retries
+// move executions back to pending.
+func checkOutcomes(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "fn")
+	execution := newExecution(t, store, trigger)
+	if _, err := store.Executions.Acquire(execution.ID, "worker", time.Now().Add(time.Minute).UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	message, trace := "boom", "trace"
+	if err := store.Executions.SaveOutcome(execution.ID, nil, &message, &trace); err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err := store.Executions.ScheduleRetry(execution.ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheduled {
+		t.Fatalf("retry of existing execution was not scheduled")
+	}
+	found := getExecution(t, store, execution.ID)
+	switch {
+	case found.Status != models.ExecutionStatusPending || found.Attempt != 2:
+		t.Fatalf("got status %s and attempt %d after retrying, want %s and 2",
+			found.Status, found.Attempt, models.ExecutionStatusPending)
+	case found.WorkerID != nil || found.LeaseExpiresAt != nil:
+		t.Fatalf("retried execution still has a lease")
+	case found.Error == nil || *found.Error != message || found.StackTrace == nil || *found.StackTrace != trace:
+		t.Fatalf("got error %v and stack trace %v, want %q and %q", found.Error, found.StackTrace, message, trace)
+	}
+
+	scheduled, err = store.Executions.ScheduleRetry(uuid.New(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled {
+		t.Fatalf("retry of unknown execution was scheduled")
+	}
+
+	result := types.JSONText(`{"ok":true}`)
+	if err := store.Executions.SaveOutcome(execution.ID, &result, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	found = getExecution(t, store, execution.ID)
+	if found.Result == nil || string(*found.Result) != string(result) {
+		t.Fatalf("got result %v, want %s", found.Result, result)
+	}
+	if found.Error != nil || found.StackTrace != nil {
+		t.Fatalf("error of the previous attempt was not cleared")
+	}
+}
+
+// checkLatestByTrigger checks that the most recently created execution of a
+// trigger is found.
+func checkLatestByTrigger(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "fn")
+	other := newTrigger(t, store, "fn")
+	newExecution(t, store, trigger)
+	latest := newExecution(t, store, trigger)
+	newExecution(t, store, other)
+
+	found, err := store.Executions.GetLatestByTriggerID(*trigger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != latest.ID {
+		t.Fatalf("got execution %v, want %s", found, latest.ID)
+	}
+
+	missing, err := store.Executions.GetLatestByTriggerID(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != nil {
+		t.Fatalf("got execution %s for an unknown trigger", missing.ID)
+	}
+}
+
+// listed creates executions for a trigger of each type, a few milliseconds
+// apart, and completes every other one. It returns the executions newest
+// first, along with the triggers.
+func listed(t *testing.T, store Store) ([]*models.Execution, *models.Trigger, *models.Trigger) {
+	t.Helper()
+	event := newTrigger(t, store, "listed")
+	cronID := uuid.New()
+	cron := &models.Trigger{ID: &cronID, TriggerType: models.TriggerTypeCron, FunctionName: "scheduled"}
+	if err := store.Triggers.Create(cron); err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+
+	var executions []*models.Execution
+	for i := range 5 {
+		trigger := event
+		if i%2 == 1 {
+			trigger = cron
+		}
+		execution := newExecution(t, store, trigger)
+		if execution.CreatedAt == nil {
+			t.Fatal("created execution has no creation time")
+		}
+		if i%2 == 0 {
+			if err := store.Executions.UpdateStatus(execution.ID, models.ExecutionStatusCompleted); err != nil {
+				t.Fatal(err)
+			}
+		}
+		executions = append([]*models.Execution{execution}, executions...)
+		time.Sleep(5 * time.Millisecond)
+	}
+	return executions, event, cron
+}
+
+// expectListed checks that the listed executions are the expected ones, in
+// the same order.
+func expectListed(t *testing.T, got []*models.ExecutionWithTrigger, want []*models.Execution) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d executions, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("got execution %s at position %d, want %s", got[i].ID, i, want[i].ID)
+		}
+	}
+}
+
+// checkList checks that executions are listed newest first, along with their
+// trigger.
+func checkList(t *testing.T, store Store) {
+	executions, event, _ := listed(t, store)
+	got, err := store.Executions.List(repositories.ExecutionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectListed(t, got, executions)
+	for _, execution := range got {
+		if execution.Trigger.ID == nil || *execution.Trigger.ID != execution.TriggerID {
+			t.Fatalf("got trigger %+v along with execution %s", execution.Trigger, execution.ID)
+		}
+	}
+	if got[0].Trigger.FunctionName != event.FunctionName || got[0].Trigger.TriggerType != event.TriggerType {
+		t.Fatalf("got trigger %+v, want %+v", got[0].Trigger, *event)
+	}
+}
+
+// checkListFilters checks that listed executions are restricted by every
+// field of the filter.
+func checkListFilters(t *testing.T, store Store) {
+	executions, event, cron := listed(t, store)
+	// Executions are newest first: the completed ones of the event trigger
+	// are at even positions, the pending ones of the CRON trigger at odd
+	// positions.
+	cases := []struct {
+		name   string
+		filter repositories.ExecutionFilter
+		want   []*models.Execution
+	}{
+		{
+			name:   "status",
+			filter: repositories.ExecutionFilter{Statuses: []models.ExecutionStatus{models.ExecutionStatusPending}},
+			want:   []*models.Execution{executions[1], executions[3]},
+		},
+		{
+			name: "statuses",
+			filter: repositories.ExecutionFilter{Statuses: []models.ExecutionStatus{
+				models.ExecutionStatusPending,
+				models.ExecutionStatusCompleted,
+			}},
+			want: executions,
+		},
+		{
+			name:   "function name",
+			filter: repositories.ExecutionFilter{FunctionName: event.FunctionName},
+			want:   []*models.Execution{executions[0], executions[2], executions[4]},
+		},
+		{
+			name:   "trigger type",
+			filter: repositories.ExecutionFilter{TriggerType: cron.TriggerType},
+			want:   []*models.Execution{executions[1], executions[3]},
+		},
+		{
+			name:   "created after",
+			filter: repositories.ExecutionFilter{CreatedAfter: executions[2].CreatedAt},
+			want:   executions[:3],
+		},
+		{
+			name:   "created before",
+			filter: repositories.ExecutionFilter{CreatedBefore: executions[2].CreatedAt},
+			want:   executions[3:],
+		},
+		{
+			name:   "limit",
+			filter: repositories.ExecutionFilter{Limit: 2},
+			want:   executions[:2],
+		},
+		{
+			name: "combined",
+			filter: repositories.ExecutionFilter{
+				Statuses:     []models.ExecutionStatus{models.ExecutionStatusCompleted},
+				FunctionName: event.FunctionName,
+				CreatedAfter: executions[3].CreatedAt,
+				Limit:        1,
+			},
+			want: executions[:1],
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := store.Executions.List(c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectListed(t, got, c.want)
+		})
+	}
+}
+
+// checkListPagination checks that following cursors lists every execution
+// exactly once.
+func checkListPagination(t *testing.T, store Store) {
+	executions, _, _ := listed(t, store)
+
+	var got []*models.ExecutionWithTrigger
+	filter := repositories.ExecutionFilter{Limit: 2}
+	for range len(executions) {
+		page, err := store.Executions.List(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, page...)
+
+		// Round-trip the cursor through its encoding, as clients do.
+		cursor, err := repositories.ParseCursor(repositories.ExecutionCursor(&page[len(page)-1].Execution).String())
+		if err != nil {
+			t.Fatalf("failed to parse cursor: %v", err)
+		}
+		filter.After = cursor
+	}
+	expectListed(t, got, executions)
+}
+
+// checkCountByFunction checks that executions are counted per function and
+// status, restricted to the requested statuses.
+func checkCountByFunction(t *testing.T, store Store) {
+	listed(t, store)
+	counts, err := store.Executions.CountByFunction([]models.ExecutionStatus{
+		models.ExecutionStatusPending,
+		models.ExecutionStatusRunning,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// `listed` completes the three executions of the "listed" function.
+	want := map[string]map[models.ExecutionStatus]int{
+		"scheduled": {models.ExecutionStatusPending: 2},
+	}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Fatalf("got counts %v, want %v", counts, want)
+	}
+}
+
+// checkTriggerLookup checks that triggers are found by their ID, along with
+// their payload and creation time.
+func checkTriggerLookup(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "looked-up")
+	found, err := store.Triggers.GetByID(*trigger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil {
+		t.Fatalf("trigger %s not found", *trigger.ID)
+	}
+	if found.FunctionName != trigger.FunctionName || found.Payload != trigger.Payload || found.Priority != trigger.Priority {
+		t.Fatalf("got trigger %+v, want %+v", *found, *trigger)
+	}
+	if found.CreatedAt == nil {
+		t.Fatalf("found trigger has no creation time")
+	}
+
+	missing, err := store.Triggers.GetByID(uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != nil {
+		t.Fatalf("found nonexistent trigger %+v", *missing)
+	}
+}
+
+// checkTriggerList checks that triggers are listed newest first, restricted
+// by the filter, and that following cursors lists every trigger exactly once.
+func checkTriggerList(t *testing.T, store Store) {
+	for i := range 5 {
+		id := uuid.New()
+		trigger := &models.Trigger{ID: &id, TriggerType: models.TriggerTypeEvent, FunctionName: "listed"}
+		if i%2 == 1 {
+			trigger.TriggerType = models.TriggerTypeCron
+			trigger.FunctionName = "scheduled"
+		}
+		if err := store.Triggers.Create(trigger); err != nil {
+			t.Fatalf("failed to create trigger: %v", err)
+		}
+	}
+
+	all, err := store.Triggers.List(repositories.TriggerFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("got %d triggers, want 5", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		previous := repositories.TriggerCursor(all[i-1])
+		if !all[i].CreatedAt.Before(previous.CreatedAt) &&
+			!(all[i].CreatedAt.Equal(previous.CreatedAt) && all[i].ID.String() < previous.ID.String()) {
+			t.Fatalf("trigger %s is listed after trigger %s", *all[i].ID, previous.ID)
+		}
+	}
+
+	for _, filter := range []repositories.TriggerFilter{
+		{FunctionName: "scheduled"},
+		{TriggerType: models.TriggerTypeCron},
+	} {
+		got, err := store.Triggers.List(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d triggers for %+v, want 2", len(got), filter)
+		}
+		for _, trigger := range got {
+			if trigger.FunctionName != "scheduled" || trigger.TriggerType != models.TriggerTypeCron {
+				t.Fatalf("got trigger %+v for %+v", *trigger, filter)
+			}
+		}
+	}
+
+	var paged []*models.Trigger
+	filter := repositories.TriggerFilter{Limit: 2}
+	for range len(all) {
+		page, err := store.Triggers.List(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+
+		// Round-trip the cursor through its encoding, as clients do.
+		cursor, err := repositories.ParseCursor(repositories.TriggerCursor(page[len(page)-1]).String())
+		if err != nil {
+			t.Fatalf("failed to parse cursor: %v", err)
+		}
+		filter.After = cursor
+	}
+	if len(paged) != len(all) {
+		t.Fatalf("got %d triggers across pages, want %d", len(paged), len(all))
+	}
+	for i := range paged {
+		if *paged[i].ID != *all[i].ID {
+			t.Fatalf("got trigger %s at position %d, want %s", *paged[i].ID, i, *all[i].ID)
+		}
+	}
+}
+
+// checkTriggerDeletion checks that `DeleteInactive` only deletes a trigger
+// once none of its executions is pending or running, that `Delete` deletes it
+// regardless, and that its executions are deleted along with it.
+func checkTriggerDeletion(t *testing.T, store Store) {
+	trigger := newTrigger(t, store, "deleted")
+	execution := newExecution(t, store, trigger)
+	other := newTrigger(t, store, "kept")
+	kept := newExecution(t, store, other)
+
+	for _, status := range []models.ExecutionStatus{models.ExecutionStatusPending, models.ExecutionStatusRunning} {
+		if err := store.Executions.UpdateStatus(execution.ID, status); err != nil {
+			t.Fatal(err)
+		}
+		deleted, err := store.Triggers.DeleteInactive(*trigger.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted {
+			t.Fatalf("deleted trigger with a %s execution", status)
+		}
+	}
+
+	if err := store.Executions.UpdateStatus(execution.ID, models.ExecutionStatusFailed); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := store.Triggers.DeleteInactive(*trigger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deleted {
+		t.Fatalf("failed to delete trigger with a finished execution")
+	}
+
+	if found, err := store.Triggers.GetByID(*trigger.ID); err != nil || found != nil {
+		t.Fatalf("got trigger %v and error %v after deletion", found, err)
+	}
+	if found, err := store.Executions.GetByID(execution.ID); err != nil || found != nil {
+		t.Fatalf("got execution %v and error %v after deleting its trigger", found, err)
+	}
+	getExecution(t, store, kept.ID)
+	listed, err := store.Executions.List(repositories.ExecutionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != kept.ID {
+		t.Fatalf("got %d executions after deletion, want only %s", len(listed), kept.ID)
+	}
+
+	deleted, err = store.Triggers.DeleteInactive(*trigger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted {
+		t.Fatalf("deleted trigger twice")
+	}
+
+	// Delete ignores the status of the executions.
+	if err := store.Triggers.Delete(*other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := store.Triggers.GetByID(*other.ID); err != nil || found != nil {
+		t.Fatalf("got trigger %v and error %v after deletion", found, err)
+	}
+	if found, err := store.Executions.GetByID(kept.ID); err != nil || found != nil {
+		t.Fatalf("got pending execution %v and error %v after deleting its trigger", found, err)
+	}
+	if err := store.Triggers.Delete(*other.ID); err != nil {
+		t.Fatal(err)
+	}
+}
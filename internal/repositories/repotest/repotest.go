@@ -0,0 +1,62 @@
+// Package repotest provides the conformance suite that every implementation
+// of the repository interfaces must pass, so that the services behave the
+// same whichever storage they are given.
+//
+// Each check runs as a subtest against a fresh store, so that a single one
+// can be selected with `go test -run`:
+//
+//	func TestMemoryRepositories(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repotest.Store {
+//			store := repositories.NewMemoryStore()
+//			return repotest.Store{Executions: store.Executions(), Triggers: store.Triggers()}
+//		})
+//	}
+package repotest
+
+import (
+	"testing"
+
+	"github.com/Pelfox/quego/internal/repositories"
+)
+
+// Store holds the repositories under test, which must share their storage:
+// executions are created for triggers created through `Triggers`.
+type Store struct {
+	Executions repositories.ExecutionRepository
+	Triggers   repositories.TriggerRepository
+}
+
+// check is a single conformance check, run against an empty store.
+type check struct {
+	name string
+	run  func(t *testing.T, store Store)
+}
+
+// checks holds every conformance check, in the order they are run.
+var checks = []check{
+	{"trigger idempotency keys", checkIdempotencyKeys},
+	{"trigger creation atomicity", checkTriggerCreationAtomicity},
+	{"execution creation", checkExecutionCreation},
+	{"execution status transitions", checkStatusTransitions},
+	{"execution leases", checkLeases},
+	{"pending execution listing", checkPendingListing},
+	{"execution outcomes and retries", checkOutcomes},
+	{"latest execution of a trigger", checkLatestByTrigger},
+	{"execution listing", checkList},
+	{"execution listing filters", checkListFilters},
+	{"execution listing pagination", checkListPagination},
+	{"execution counts by function", checkCountByFunction},
+	{"trigger lookup", checkTriggerLookup},
+	{"trigger listing", checkTriggerList},
+	{"trigger deletion", checkTriggerDeletion},
+}
+
+// Run runs every conformance check as a subtest of `t`, against a fresh
+// store returned by `newStore`.
+func Run(t *testing.T, newStore func(t *testing.T) Store) {
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			check.run(t, newStore(t))
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ScheduleRepository handles database operations for `Schedule` entities.
+type ScheduleRepository struct {
+	db *sqlx.DB
+}
+
+// NewScheduleRepository creates a new `ScheduleRepository` backed by the
+// given `sqlx.DB` instance.
+func NewScheduleRepository(db *sqlx.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+// Create inserts a new `Schedule` record into the database.
+func (r *ScheduleRepository) Create(data *models.Schedule) error {
+	query := `
+	INSERT INTO schedules (id, function_name, expression, payload, enabled, next_run_at, created_at)
+	VALUES (:id, :function_name, :expression, :payload, :enabled, :next_run_at, :created_at)
+	`
+	_, err := r.db.NamedExec(query, data)
+	return err
+}
+
+// GetByID retrieves a `Schedule` model by its unique identifier. It returns
+// nil without an error if no such schedule exists.
+func (r *ScheduleRepository) GetByID(id uuid.UUID) (*models.Schedule, error) {
+	var schedule models.Schedule
+	query := "SELECT * FROM schedules WHERE id = ?"
+	if err := r.db.Get(&schedule, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListAll retrieves all `Schedule` records from the database, ordered by
+// creation time in descending order.
+func (r *ScheduleRepository) ListAll() ([]*models.Schedule, error) {
+	schedules := []*models.Schedule{}
+	query := "SELECT * FROM schedules ORDER BY created_at DESC"
+	if err := r.db.Select(&schedules, query); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// ListDue retrieves all enabled schedules whose next run time is at or
+// before `now`.
+func (r *ScheduleRepository) ListDue(now time.Time) ([]*models.Schedule, error) {
+	var schedules []*models.Schedule
+	query := "SELECT * FROM schedules WHERE enabled = TRUE AND next_run_at <= ?"
+	if err := r.db.Select(&schedules, query, now); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// Update overwrites the mutable fields of a `Schedule`: its expression,
+// payload, enabled flag and next run time.
+func (r *ScheduleRepository) Update(data *models.Schedule) error {
+	query := `
+	UPDATE schedules
+	SET expression = :expression, payload = :payload, enabled = :enabled, next_run_at = :next_run_at
+	WHERE id = :id
+	`
+	_, err := r.db.NamedExec(query, data)
+	return err
+}
+
+// Advance moves a schedule from its current run to the next one. The update
+// only succeeds if the schedule's next run time still equals `expected`, so
+// that when several instances observe the same due schedule, only one of
+// them fires it. It reports whether the schedule was advanced.
+func (r *ScheduleRepository) Advance(id uuid.UUID, expected time.Time, lastRun time.Time, next *time.Time) (bool, error) {
+	query := "UPDATE schedules SET last_run_at = ?, next_run_at = ? WHERE id = ? AND next_run_at = ?"
+	result, err := r.db.Exec(query, lastRun, next, id, expected)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// Delete removes a `Schedule` record from the database. Triggers created by
+// the schedule are kept, but lose their reference to it.
+func (r *ScheduleRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM schedules WHERE id = ?", id)
+	return err
+}
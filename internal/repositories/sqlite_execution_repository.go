@@ -0,0 +1,506 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+)
+
+// SQLiteExecutionRepository is an `ExecutionRepository` storing `Execution`
+// entities in a SQLite database. It also keeps the state of the jobs queued in
+// the database, alongside their execution.
+type SQLiteExecutionRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteExecutionRepository creates a new `SQLiteExecutionRepository`
+// backed by the given `sqlx.DB` instance.
+func NewSQLiteExecutionRepository(db *sqlx.DB) *SQLiteExecutionRepository {
+	return &SQLiteExecutionRepository{db: db}
+}
+
+// Create inserts a new `Execution` record into the database. The
+// provided `Execution` struct must include values for `id`, `status`,
+// `trigger_id` and `attempt`, and may include `scheduled_at`. Its
+// `created_at` is set to the current time.
+func (r *SQLiteExecutionRepository) Create(data *models.Execution) error {
+	return insertExecution(r.db, data)
+}
+
+// insertExecution inserts a new `Execution` record through the given
+// database or transaction, and sets its `created_at` to the current time.
+func insertExecution(db sqlx.Ext, data *models.Execution) error {
+	createdAt := time.Now().UTC()
+	data.CreatedAt = &createdAt
+	query := `
+	INSERT INTO executions (id, status, trigger_id, attempt, priority, created_at, scheduled_at)
+	VALUES (:id, :status, :trigger_id, :attempt, :priority, :created_at, :scheduled_at)
+	`
+	_, err := sqlx.NamedExec(db, query, data)
+	return err
+}
+
+// UpdateStatus updates the status of an `Execution` model in the database. In
+// addition to the status field, it conditionally updates timestamp fields
+// depending on the new status:
+// - `ExecutionStatusRunning`: updates `started_at`.
+// - `ExecutionStatusCompleted`, `ExecutionStatusFailed`,
+// `ExecutionStatusTimedOut`, `ExecutionStatusCancelled` or
+// `ExecutionStatusDead`: updates `finished_at`.
+func (r *SQLiteExecutionRepository) UpdateStatus(id uuid.UUID, newStatus models.ExecutionStatus) error {
+	_, err := r.updateStatus(id, nil, newStatus)
+	return err
+}
+
+// CompareAndUpdateStatus behaves like `UpdateStatus`, but only updates the
+// `Execution` if its current status equals `expected`. It reports whether the
+// execution was updated, which allows concurrent workers and API calls to
+// agree on a single status transition.
+func (r *SQLiteExecutionRepository) CompareAndUpdateStatus(
+	id uuid.UUID,
+	expected models.ExecutionStatus,
+	newStatus models.ExecutionStatus,
+) (bool, error) {
+	return r.updateStatus(id, &expected, newStatus)
+}
+
+// updateStatus implements `UpdateStatus` and `CompareAndUpdateStatus`. If
+// `expected` is not nil, only an execution in that status is updated.
+func (r *SQLiteExecutionRepository) updateStatus(
+	id uuid.UUID,
+	expected *models.ExecutionStatus,
+	newStatus models.ExecutionStatus,
+) (bool, error) {
+	query := "UPDATE executions SET status = ?"
+	args := []any{newStatus}
+
+	switch {
+	case newStatus == models.ExecutionStatusRunning:
+		query += ", started_at = ?"
+		args = append(args, time.Now())
+	case newStatus.IsTerminal():
+		query += ", finished_at = ?"
+		args = append(args, time.Now())
+	}
+	// Only running executions hold a lease.
+	if newStatus != models.ExecutionStatusRunning {
+		query += ", worker_id = NULL, lease_expires_at = NULL"
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, id)
+	if expected != nil {
+		query += " AND status = ?"
+		args = append(args, *expected)
+	}
+
+	return r.execAffectsOne(query, args...)
+}
+
+// execAffectsOne executes a statement and reports whether it affected exactly
+// one row.
+func (r *SQLiteExecutionRepository) execAffectsOne(query string, args ...any) (bool, error) {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// SaveOutcome stores the outcome of the latest attempt of an `Execution`: the
+// result of a successful attempt, or the error message and optional stack
+// trace of a failed one. Values that are nil are cleared.
+func (r *SQLiteExecutionRepository) SaveOutcome(
+	id uuid.UUID,
+	result *types.JSONText,
+	errorMessage *string,
+	stackTrace *string,
+) error {
+	query := "UPDATE executions SET result = ?, error = ?, stack_trace = ? WHERE id = ?"
+	_, err := r.db.Exec(query, result, errorMessage, stackTrace, id)
+	return err
+}
+
+// ScheduleRetry moves a failed `Execution` back to the `Pending` state and
+// records the number of the attempt it is waiting for.
+func (r *SQLiteExecutionRepository) ScheduleRetry(id uuid.UUID, attempt int) (bool, error) {
+	query := `
+	UPDATE executions
+	SET status = ?, attempt = ?, worker_id = NULL, lease_expires_at = NULL
+	WHERE id = ?
+	`
+	return r.execAffectsOne(query, models.ExecutionStatusPending, attempt, id)
+}
+
+// Acquire moves a pending `Execution` to the `Running` state and grants the
+// given worker a lease on it until `leaseExpiresAt`. It reports whether the
+// execution was acquired, which is not the case if it is no longer pending.
+func (r *SQLiteExecutionRepository) Acquire(id uuid.UUID, workerID string, leaseExpiresAt time.Time) (bool, error) {
+	query := `
+	UPDATE executions
+	SET status = ?, started_at = ?, worker_id = ?, lease_expires_at = ?
+	WHERE id = ? AND status = ?
+	`
+	return r.execAffectsOne(
+		query,
+		models.ExecutionStatusRunning,
+		time.Now(),
+		workerID,
+		leaseExpiresAt,
+		id,
+		models.ExecutionStatusPending,
+	)
+}
+
+// RenewLease extends the lease a worker holds on a running `Execution` until
+// `leaseExpiresAt`. It reports whether the lease was renewed, which is not the
+// case if the worker no longer holds it.
+func (r *SQLiteExecutionRepository) RenewLease(id uuid.UUID, workerID string, leaseExpiresAt time.Time) (bool, error) {
+	query := "UPDATE executions SET lease_expires_at = ? WHERE id = ? AND status = ? AND worker_id = ?"
+	return r.execAffectsOne(query, leaseExpiresAt, id, models.ExecutionStatusRunning, workerID)
+}
+
+// ReleaseExpiredLease moves a running `Execution` whose lease has expired at
+// `now` back to the `Pending` state. It reports whether the execution was
+// released, so that when several instances observe the same expired lease,
+// only one of them requeues the execution.
+func (r *SQLiteExecutionRepository) ReleaseExpiredLease(id uuid.UUID, now time.Time) (bool, error) {
+	query := `
+	UPDATE executions
+	SET status = ?, worker_id = NULL, lease_expires_at = NULL
+	WHERE id = ? AND status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)
+	`
+	return r.execAffectsOne(query, models.ExecutionStatusPending, id, models.ExecutionStatusRunning, now)
+}
+
+// GetByID retrieves an `Execution` model by its unique identifier. It returns
+// nil without an error if no such execution exists.
+func (r *SQLiteExecutionRepository) GetByID(id uuid.UUID) (*models.Execution, error) {
+	var execution models.Execution
+	query := "SELECT * FROM executions WHERE id = ?"
+	if err := r.db.Get(&execution, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// GetLatestByTriggerID retrieves the most recently created `Execution` of the
+// given trigger. It returns nil without an error if the trigger has no
+// executions.
+func (r *SQLiteExecutionRepository) GetLatestByTriggerID(triggerID uuid.UUID) (*models.Execution, error) {
+	var execution models.Execution
+	query := "SELECT * FROM executions WHERE trigger_id = ? ORDER BY rowid DESC LIMIT 1"
+	if err := r.db.Get(&execution, query, triggerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// List retrieves the `Execution` records matching the filter, newest
+// first, along with a summary of their trigger. Timestamps are compared in
+// UTC, the time zone they are written in.
+func (r *SQLiteExecutionRepository) List(filter ExecutionFilter) ([]*models.ExecutionWithTrigger, error) {
+	var conditions []string
+	var args []any
+	if len(filter.Statuses) > 0 {
+		placeholders := strings.Repeat(", ?", len(filter.Statuses))[2:]
+		conditions = append(conditions, "e.status IN ("+placeholders+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.FunctionName != "" {
+		conditions = append(conditions, "t.function_name = ?")
+		args = append(args, filter.FunctionName)
+	}
+	if filter.TriggerType != "" {
+		conditions = append(conditions, "t.trigger_type = ?")
+		args = append(args, filter.TriggerType)
+	}
+	if filter.TriggerID != nil {
+		conditions = append(conditions, "e.trigger_id = ?")
+		args = append(args, *filter.TriggerID)
+	}
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "e.created_at >= ?")
+		args = append(args, filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "e.created_at < ?")
+		args = append(args, filter.CreatedBefore.UTC())
+	}
+	if filter.After != nil {
+		conditions = append(conditions, "(e.created_at < ? OR (e.created_at = ? AND e.id < ?))")
+		args = append(args, filter.After.CreatedAt, filter.After.CreatedAt, filter.After.ID)
+	}
+
+	query := `
+	SELECT
+		e.*,
+		t.id AS "trigger.id",
+		t.trigger_type AS "trigger.trigger_type",
+		t.function_name AS "trigger.function_name"
+	FROM executions e
+	JOIN triggers t ON e.trigger_id = t.id
+	`
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + "\n"
+	}
+	query += "ORDER BY e.created_at DESC, e.id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	var executions []*models.ExecutionWithTrigger
+	if err := r.db.Select(&executions, query, args...); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// CountByFunction counts the `Execution` records in each of the given
+// statuses, keyed by the function name of their trigger.
+func (r *SQLiteExecutionRepository) CountByFunction(
+	statuses []models.ExecutionStatus,
+) (map[string]map[models.ExecutionStatus]int, error) {
+	counts := make(map[string]map[models.ExecutionStatus]int)
+	if len(statuses) == 0 {
+		return counts, nil
+	}
+
+	args := make([]any, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	query := `
+	SELECT t.function_name, e.status, COUNT(*) AS count
+	FROM executions e
+	JOIN triggers t ON e.trigger_id = t.id
+	WHERE e.status IN (` + strings.Repeat(", ?", len(statuses))[2:] + `)
+	GROUP BY t.function_name, e.status
+	`
+	var rows []struct {
+		FunctionName string                 `db:"function_name"`
+		Status       models.ExecutionStatus `db:"status"`
+		Count        int                    `db:"count"`
+	}
+	if err := r.db.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if counts[row.FunctionName] == nil {
+			counts[row.FunctionName] = make(map[models.ExecutionStatus]int)
+		}
+		counts[row.FunctionName][row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// ListExpiredLeases retrieves all running `Execution` records whose lease has
+// expired at `now`, along with their triggers. Running executions without a
+// lease (e.g. started by an older version) are considered expired as well.
+func (r *SQLiteExecutionRepository) ListExpiredLeases(now time.Time) ([]*models.ExecutionWithTrigger, error) {
+	var expired []*models.ExecutionWithTrigger
+	query := `
+	SELECT
+		e.*,
+		t.id AS "trigger.id",
+		t.trigger_type AS "trigger.trigger_type",
+		t.function_name AS "trigger.function_name",
+		t.payload AS "trigger.payload",
+		t.priority AS "trigger.priority"
+	FROM executions e
+	JOIN triggers t ON e.trigger_id = t.id
+	WHERE e.status = ? AND (e.lease_expires_at IS NULL OR e.lease_expires_at < ?)
+	`
+	if err := r.db.Select(&expired, query, models.ExecutionStatusRunning, now); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// ListPending retrieves all pending `Execution` records created before
+// `createdBefore`, along with their triggers, oldest first.
+func (r *SQLiteExecutionRepository) ListPending(createdBefore time.Time) ([]*models.ExecutionWithTrigger, error) {
+	var pending []*models.ExecutionWithTrigger
+	query := `
+	SELECT
+		e.*,
+		t.id AS "trigger.id",
+		t.trigger_type AS "trigger.trigger_type",
+		t.function_name AS "trigger.function_name",
+		t.payload AS "trigger.payload",
+		t.priority AS "trigger.priority"
+	FROM executions e
+	JOIN triggers t ON e.trigger_id = t.id
+	WHERE e.status = ? AND e.created_at < ?
+	ORDER BY e.created_at, e.id
+	`
+	if err := r.db.Select(&pending, query, models.ExecutionStatusPending, createdBefore); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// EnqueueJob adds the job of an `Execution` to the named queue with the given
+// score, releasing any claim on it. If `availableAt` is not nil, the job may
+// not be claimed before then.
+func (r *SQLiteExecutionRepository) EnqueueJob(id uuid.UUID, queue string, score float64, availableAt *time.Time) error {
+	query := `
+	UPDATE executions
+	SET queue = ?, queue_score = ?, available_at = ?, claimed_by = NULL, claimed_at = NULL
+	WHERE id = ?
+	`
+	_, err := r.db.Exec(query, queue, score, availableAt, id)
+	return err
+}
+
+// RestoreJob behaves like `EnqueueJob` for a pending `Execution` whose job is
+// neither queued nor claimed. It reports whether the job was added.
+func (r *SQLiteExecutionRepository) RestoreJob(
+	id uuid.UUID,
+	queue string,
+	score float64,
+	availableAt *time.Time,
+) (bool, error) {
+	query := `
+	UPDATE executions
+	SET queue = ?, queue_score = ?, available_at = ?
+	WHERE id = ? AND status = ? AND queue_score IS NULL AND claimed_by IS NULL
+	`
+	return r.execAffectsOne(query, queue, score, availableAt, id, models.ExecutionStatusPending)
+}
+
+// ClaimJob atomically claims the pending job with the lowest score on the
+// named queue that is available at `now`, on behalf of `claimant`. It returns
+// the claimed `Execution` along with its trigger, or nil without an error if
+// no job is available.
+func (r *SQLiteExecutionRepository) ClaimJob(
+	queue string,
+	claimant string,
+	now time.Time,
+) (*models.ExecutionWithTrigger, error) {
+	query := `
+	UPDATE executions
+	SET claimed_by = ?, claimed_at = ?
+	WHERE id = (
+		SELECT id FROM executions
+		WHERE queue = ?
+			AND queue_score IS NOT NULL
+			AND claimed_by IS NULL
+			AND status = ?
+			AND (available_at IS NULL OR available_at <= ?)
+		ORDER BY queue_score
+		LIMIT 1
+	) AND claimed_by IS NULL
+	RETURNING id
+	`
+	var id uuid.UUID
+	err := r.db.Get(&id, query, claimant, now, queue, models.ExecutionStatusPending, now)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var job models.ExecutionWithTrigger
+	query = `
+	SELECT
+		e.*,
+		t.id AS "trigger.id",
+		t.trigger_type AS "trigger.trigger_type",
+		t.function_name AS "trigger.function_name",
+		t.payload AS "trigger.payload",
+		t.priority AS "trigger.priority"
+	FROM executions e
+	JOIN triggers t ON e.trigger_id = t.id
+	WHERE e.id = ?
+	`
+	if err := r.db.Get(&job, query, id); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// AckJob removes the job of an `Execution` from its queue, if it is still
+// claimed by `claimant`.
+func (r *SQLiteExecutionRepository) AckJob(id uuid.UUID, claimant string) error {
+	query := `
+	UPDATE executions
+	SET queue_score = NULL, available_at = NULL, claimed_by = NULL, claimed_at = NULL
+	WHERE id = ? AND claimed_by = ?
+	`
+	_, err := r.db.Exec(query, id, claimant)
+	return err
+}
+
+// ReturnJob releases the claim of `claimant` on the job of an `Execution`,
+// giving the job the provided score.
+func (r *SQLiteExecutionRepository) ReturnJob(id uuid.UUID, claimant string, score float64) error {
+	query := `
+	UPDATE executions
+	SET queue_score = ?, available_at = NULL, claimed_by = NULL, claimed_at = NULL
+	WHERE id = ? AND claimed_by = ?
+	`
+	_, err := r.db.Exec(query, score, id, claimant)
+	return err
+}
+
+// ReturnClaimedJobs releases every claim of `claimant`, giving the released
+// jobs the provided score.
+func (r *SQLiteExecutionRepository) ReturnClaimedJobs(claimant string, score float64) error {
+	query := `
+	UPDATE executions
+	SET queue_score = ?, available_at = NULL, claimed_by = NULL, claimed_at = NULL
+	WHERE claimed_by = ? AND queue_score IS NOT NULL
+	`
+	_, err := r.db.Exec(query, score, claimant)
+	return err
+}
+
+// ReturnStaleJobs releases the claims made before `claimedBefore` on jobs
+// whose execution is still pending, giving the released jobs the provided
+// score. It returns the number of released jobs.
+func (r *SQLiteExecutionRepository) ReturnStaleJobs(claimedBefore time.Time, score float64) (int64, error) {
+	query := `
+	UPDATE executions
+	SET queue_score = ?, available_at = NULL, claimed_by = NULL, claimed_at = NULL
+	WHERE claimed_by IS NOT NULL AND claimed_at < ? AND status = ? AND queue_score IS NOT NULL
+	`
+	result, err := r.db.Exec(query, score, claimedBefore, models.ExecutionStatusPending)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RemoveJob removes the job of an `Execution` from the named queue, unless it
+// has been claimed.
+func (r *SQLiteExecutionRepository) RemoveJob(id uuid.UUID, queue string) error {
+	query := `
+	UPDATE executions
+	SET queue_score = NULL, available_at = NULL
+	WHERE id = ? AND queue = ? AND claimed_by IS NULL
+	`
+	_, err := r.db.Exec(query, id, queue)
+	return err
+}
@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// SQLiteTriggerRepository is a `TriggerRepository` storing `Trigger` entities
+// in a SQLite database.
+type SQLiteTriggerRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteTriggerRepository creates a new `SQLiteTriggerRepository` backed
+// by the given `sqlx.DB` instance.
+func NewSQLiteTriggerRepository(db *sqlx.DB) *SQLiteTriggerRepository {
+	return &SQLiteTriggerRepository{db: db}
+}
+
+// Create inserts a new `Trigger` record into the database.
+func (r *SQLiteTriggerRepository) Create(data *models.Trigger) error {
+	query := `
+	INSERT INTO triggers (id, trigger_type, function_name, payload, priority, schedule_id, idempotency_key)
+	VALUES (:id, :trigger_type, :function_name, :payload, :priority, :schedule_id, :idempotency_key)
+	`
+	_, err := r.db.NamedExec(query, data)
+	return err
+}
+
+// CreateWithExecution inserts a new `Trigger` record along with its first
+// `Execution` record in a single transaction. If the trigger carries an
+// idempotency key, the trigger is only inserted if no other trigger with the
+// same key has been created within the given window; the check and the
+// insert happen in a single statement, so concurrent requests with the same
+// key create at most one trigger. It reports whether the records were
+// inserted.
+func (r *SQLiteTriggerRepository) CreateWithExecution(
+	data *models.Trigger,
+	execution *models.Execution,
+	window time.Duration,
+) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO triggers (id, trigger_type, function_name, payload, priority, schedule_id, idempotency_key)
+	SELECT ?, ?, ?, ?, ?, ?, ?
+	WHERE ? IS NULL OR NOT EXISTS (
+		SELECT 1 FROM triggers WHERE idempotency_key = ? AND created_at >= datetime('now', ?)
+	)
+	`
+	result, err := tx.Exec(
+		query,
+		data.ID,
+		data.TriggerType,
+		data.FunctionName,
+		data.Payload,
+		data.Priority,
+		data.ScheduleID,
+		data.IdempotencyKey,
+		data.IdempotencyKey,
+		data.IdempotencyKey,
+		windowModifier(window),
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := insertExecution(tx, execution); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByIdempotencyKey retrieves the most recent `Trigger` with the given
+// idempotency key that has been created within the given window. It returns
+// nil without an error if there is no such trigger.
+func (r *SQLiteTriggerRepository) GetByIdempotencyKey(key string, window time.Duration) (*models.Trigger, error) {
+	var trigger models.Trigger
+	query := `
+	SELECT * FROM triggers
+	WHERE idempotency_key = ? AND created_at >= datetime('now', ?)
+	ORDER BY created_at DESC
+	LIMIT 1
+	`
+	if err := r.db.Get(&trigger, query, key, windowModifier(window)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trigger, nil
+}
+
+// GetByID retrieves a `Trigger` model by its unique identifier. It returns
+// nil without an error if no such trigger exists.
+func (r *SQLiteTriggerRepository) GetByID(id uuid.UUID) (*models.Trigger, error) {
+	var trigger models.Trigger
+	if err := r.db.Get(&trigger, "SELECT * FROM triggers WHERE id = ?", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &trigger, nil
+}
+
+// List retrieves the `Trigger` records matching the filter, newest first.
+// Cursors are compared through SQLite's `datetime`, as `created_at` is filled
+// in by the database itself.
+func (r *SQLiteTriggerRepository) List(filter TriggerFilter) ([]*models.Trigger, error) {
+	var conditions []string
+	var args []any
+	if filter.FunctionName != "" {
+		conditions = append(conditions, "function_name = ?")
+		args = append(args, filter.FunctionName)
+	}
+	if filter.TriggerType != "" {
+		conditions = append(conditions, "trigger_type = ?")
+		args = append(args, filter.TriggerType)
+	}
+	if filter.After != nil {
+		conditions = append(conditions, "(created_at < datetime(?) OR (created_at = datetime(?) AND id < ?))")
+		args = append(args, filter.After.CreatedAt, filter.After.CreatedAt, filter.After.ID)
+	}
+
+	query := "SELECT * FROM triggers"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	var triggers []*models.Trigger
+	if err := r.db.Select(&triggers, query, args...); err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// DeleteInactive deletes a `Trigger` record, unless one of its executions is
+// pending or running. Its executions are deleted along with it by the
+// `ON DELETE CASCADE` constraint.
+func (r *SQLiteTriggerRepository) DeleteInactive(id uuid.UUID) (bool, error) {
+	query := `
+	DELETE FROM triggers
+	WHERE id = ? AND NOT EXISTS (
+		SELECT 1 FROM executions WHERE trigger_id = ? AND status IN (?, ?)
+	)
+	`
+	result, err := r.db.Exec(query, id, id, models.ExecutionStatusPending, models.ExecutionStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// Delete deletes a `Trigger` record. Its executions are deleted along with it
+// by the `ON DELETE CASCADE` constraint.
+func (r *SQLiteTriggerRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM triggers WHERE id = ?", id)
+	return err
+}
+
+// windowModifier converts a window into an SQLite date modifier that moves a
+// timestamp back by the window's length. Timestamps are compared through
+// SQLite's `datetime`, as `created_at` is filled in by the database itself.
+func windowModifier(window time.Duration) string {
+	return fmt.Sprintf("-%d seconds", int64(window.Seconds()))
+}
@@ -1,24 +1,56 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/Pelfox/quego/models"
-	"github.com/jmoiron/sqlx"
+	"github.com/google/uuid"
 )
 
-// TriggerRepository handles database operations for `Trigger` entities.
-type TriggerRepository struct {
-	db *sqlx.DB
-}
+// TriggerRepository stores `Trigger` entities. Implementations must be safe
+// for concurrent use.
+type TriggerRepository interface {
+	// Create stores a new `Trigger`. Its creation time is set by the
+	// repository.
+	Create(data *models.Trigger) error
+	// CreateWithExecution stores a new `Trigger` along with its first
+	// `Execution`, as `ExecutionRepository.Create` does, in a single
+	// transaction: either both are stored or neither is. If the trigger
+	// carries an idempotency key, nothing is stored if another trigger with
+	// the same key has been created within the given window. The check and
+	// the inserts are atomic, so that concurrent requests with the same key
+	// create at most one trigger. It reports whether the trigger was stored.
+	CreateWithExecution(data *models.Trigger, execution *models.Execution, window time.Duration) (bool, error)
+	// GetByIdempotencyKey retrieves the most recent `Trigger` with the given
+	// idempotency key that has been created within the given window. It
+	// returns nil without an error if there is no such trigger.
+	GetByIdempotencyKey(key string, window time.Duration) (*models.Trigger, error)
 
-// NewTriggerRepository creates a new `TriggerRepository` backed by the
-// given `sqlx.DB` instance.
-func NewTriggerRepository(db *sqlx.DB) *TriggerRepository {
-	return &TriggerRepository{db: db}
+	// GetByID retrieves a `Trigger` by its unique identifier. It returns nil
+	// without an error if no such trigger exists.
+	GetByID(id uuid.UUID) (*models.Trigger, error)
+	// List retrieves the triggers matching the filter, newest first.
+	// Triggers created at the same time are ordered by descending ID.
+	List(filter TriggerFilter) ([]*models.Trigger, error)
+	// DeleteInactive deletes a `Trigger` along with its executions, unless
+	// one of them is pending or running. The check and the deletion are
+	// atomic. It reports whether the trigger was deleted.
+	DeleteInactive(id uuid.UUID) (bool, error)
+	// Delete deletes a `Trigger` along with its executions, whatever their
+	// status. Deleting a trigger that does not exist is not an error.
+	Delete(id uuid.UUID) error
 }
 
-// Create inserts a new `Trigger` record into the database.
-func (r *TriggerRepository) Create(data *models.Trigger) error {
-	query := "INSERT INTO triggers (id, trigger_type, function_name, payload) VALUES (:id, :trigger_type, :function_name, :payload)"
-	_, err := r.db.NamedExec(query, data)
-	return err
+// TriggerFilter selects the triggers retrieved by `TriggerRepository.List`.
+// Zero values select every trigger.
+type TriggerFilter struct {
+	// FunctionName restricts the triggers to those of the given function.
+	FunctionName string
+	// TriggerType restricts the triggers to those of the given type.
+	TriggerType models.TriggerType
+	// After restricts the triggers to those listed after the given position,
+	// in order to retrieve the next page of a listing.
+	After *Cursor
+	// Limit is the maximum number of triggers to retrieve, if positive.
+	Limit int
 }
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+var (
+	// ErrExecutionNotFound is returned when an operation refers to an
+	// `Execution` that does not exist.
+	ErrExecutionNotFound = errors.New("the requested execution does not exist")
+	// ErrExecutionNotCancellable is returned when cancelling an execution that
+	// has already finished.
+	ErrExecutionNotCancellable = errors.New("the execution has already finished")
+	// ErrExecutionCancelled is the cause attached to the context of an
+	// execution that has been cancelled on request.
+	ErrExecutionCancelled = errors.New("the execution has been cancelled")
+	// ErrShuttingDown is the cause attached to the context of an execution
+	// that is interrupted because the service is shutting down.
+	ErrShuttingDown = errors.New("the execution service is shutting down")
+)
+
+// CancelExecution stops the execution with the given ID.
+//
+// A pending execution is marked as cancelled right away and its job is
+// removed from the queue. For a running execution, a cancellation request is
+// broadcast to all instances; the one running it cancels the execution's
+// context, and the execution is marked as cancelled once its function
+// returns. The returned execution reflects the state right after the request.
+//
+// It returns `ErrExecutionNotFound` if the execution does not exist, and
+// `ErrExecutionNotCancellable` if it has already finished.
+func (s *ExecutionService) CancelExecution(id uuid.UUID) (*models.Execution, error) {
+	execution, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if execution == nil {
+		return nil, ErrExecutionNotFound
+	}
+
+	if execution.Status == models.ExecutionStatusPending {
+		cancelled, err := s.repo.CompareAndUpdateStatus(
+			id,
+			models.ExecutionStatusPending,
+			models.ExecutionStatusCancelled,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if cancelled {
+			// Workers skip executions that are no longer pending, so failing
+			// to remove the job only leaves a stale entry behind.
+			if err := s.removeQueuedJob(id); err != nil {
+				log.Errorf("Failed to remove cancelled job %s from the queue: %v", id, err)
+			}
+			return s.repo.GetByID(id)
+		}
+
+		// The execution has started in the meantime.
+		if execution, err = s.repo.GetByID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if execution.Status != models.ExecutionStatusRunning {
+		return nil, ErrExecutionNotCancellable
+	}
+
+	s.cancelRunning(id)
+	if err := s.coordinator.PublishCancellation(context.Background(), id); err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+// removeQueuedJob removes every job of the given execution from the known
+// queues.
+func (s *ExecutionService) removeQueuedJob(id uuid.UUID) error {
+	for _, queue := range s.queueNames() {
+		if err := s.queue.Remove(context.Background(), queue, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenForCancellations cancels the executions running on this instance
+// whose cancellation has been requested on any instance, until the provided
+// context is canceled.
+func (s *ExecutionService) listenForCancellations(ctx context.Context) {
+	for id := range s.coordinator.Cancellations(ctx) {
+		s.cancelRunning(id)
+	}
+}
+
+// trackRunning records the cancel function of an execution that started
+// running on this instance.
+func (s *ExecutionService) trackRunning(id uuid.UUID, cancel context.CancelCauseFunc) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	s.running[id] = cancel
+}
+
+// untrackRunning forgets an execution that stopped running on this instance.
+func (s *ExecutionService) untrackRunning(id uuid.UUID) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	delete(s.running, id)
+}
+
+// cancelRunning cancels the context of the given execution if it is running
+// on this instance.
+func (s *ExecutionService) cancelRunning(id uuid.UUID) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	if cancel, ok := s.running[id]; ok {
+		cancel(ErrExecutionCancelled)
+	}
+}
+
+// cancelAllRunning cancels the context of every execution running on this
+// instance with the given cause.
+func (s *ExecutionService) cancelAllRunning(cause error) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	for _, cancel := range s.running {
+		cancel(cause)
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+// lockRetryDelay is how long a job waiting for a function's lock or slots is
+// delayed before it checks them again.
+const lockRetryDelay = time.Second
+
+// acquireLock enforces the concurrency mode of a function before one of its
+// jobs starts. It reports whether the job may start; if so, the returned name
+// is that of the lock now held by the execution, or empty if the function
+// allows concurrent executions.
+//
+// A job that may not start is handled according to the mode: it is marked as
+// skipped with `ConcurrencySkip`, or delayed until the lock is free with
+// `ConcurrencyQueue` and `ConcurrencyReplace`. With `ConcurrencyReplace`, the
+// execution holding the lock is cancelled as well.
+func (s *ExecutionService) acquireLock(
+	payload *models.ExecutionWithTrigger,
+	mode models.ConcurrencyMode,
+) (string, bool) {
+	if mode == "" || mode == models.ConcurrencyAllow {
+		return "", true
+	}
+
+	ctx := context.Background()
+	id := payload.Execution.ID
+	name := payload.Trigger.FunctionName
+
+	// The lock expires with the lease of the holder, so that it is not kept
+	// forever by a crashed instance.
+	acquired, err := s.coordinator.TryLock(ctx, name, id.String(), leaseDuration)
+	if err != nil {
+		log.Errorf("Failed to acquire lock for job %s, delaying it: %v", id, err)
+		s.waitForLock(payload)
+		return "", false
+	}
+	if acquired {
+		return name, true
+	}
+	if !s.stillPending(id) {
+		// Jobs that are no longer pending must neither wait for the lock nor
+		// replace its holder; they are skipped once they fail to start.
+		return "", true
+	}
+
+	switch mode {
+	case models.ConcurrencySkip:
+		skipped, err := s.repo.CompareAndUpdateStatus(
+			id,
+			models.ExecutionStatusPending,
+			models.ExecutionStatusSkipped,
+		)
+		if err != nil {
+			log.Errorf("Failed to update status for job %s: %v", id, err)
+		} else if skipped {
+			log.Infof("Skipping job %s as %s is already running", id, name)
+		}
+		return "", false
+	case models.ConcurrencyReplace:
+		holder, err := s.coordinator.LockHolder(ctx, name)
+		if err != nil {
+			log.Errorf("Failed to look up lock holder of job %s: %v", id, err)
+		}
+		if holderID, err := uuid.Parse(holder); err == nil && holderID != id {
+			if _, err := s.CancelExecution(holderID); err != nil {
+				log.Warnf("Failed to cancel job %s replaced by job %s: %v", holderID, id, err)
+			}
+		}
+	}
+	s.waitForLock(payload)
+	return "", false
+}
+
+// holdLock refreshes the lock held by a running execution every
+// `heartbeatInterval`, until the provided context is done.
+func (s *ExecutionService) holdLock(ctx context.Context, name string, id uuid.UUID) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.coordinator.RefreshLock(context.Background(), name, id.String(), leaseDuration)
+			if err != nil {
+				log.Errorf("Failed to refresh lock for job %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// releaseLock releases the lock held by an execution, unless it has expired
+// and been taken over in the meantime.
+func (s *ExecutionService) releaseLock(name string, id uuid.UUID) {
+	if err := s.coordinator.Unlock(context.Background(), name, id.String()); err != nil {
+		log.Errorf("Failed to release lock for job %s: %v", id, err)
+	}
+}
+
+// acquireSlot enforces the maximum concurrency of a function before one of
+// its jobs starts, by claiming one of the function's slots for the execution.
+// It reports whether the job may start, and whether a slot has been claimed
+// for it, which must then be released. A job over the limit stays pending and
+// is delayed until a slot is checked for again. A limit of zero means no
+// limit.
+//
+// A duplicate job of an execution that already holds a slot may start without
+// claiming one; it is skipped once it fails to start.
+func (s *ExecutionService) acquireSlot(payload *models.ExecutionWithTrigger, limit int) (bool, bool) {
+	if limit <= 0 {
+		return false, true
+	}
+
+	id := payload.Execution.ID
+	claimed, held, err := s.coordinator.AcquireSlot(
+		context.Background(),
+		payload.Trigger.FunctionName,
+		id.String(),
+		limit,
+		leaseDuration,
+	)
+	switch {
+	case err != nil:
+		log.Errorf("Failed to acquire slot for job %s, delaying it: %v", id, err)
+	case claimed || held:
+		return claimed, true
+	case !s.stillPending(id):
+		return false, true
+	default:
+		log.Infof("Delaying job %s as %s is at its concurrency limit", id, payload.Trigger.FunctionName)
+	}
+	s.waitForLock(payload)
+	return false, false
+}
+
+// holdSlot refreshes the slot held by a running execution every
+// `heartbeatInterval`, until the provided context is done.
+func (s *ExecutionService) holdSlot(ctx context.Context, functionName string, id uuid.UUID) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.coordinator.RefreshSlot(context.Background(), functionName, id.String(), leaseDuration)
+			if err != nil {
+				log.Errorf("Failed to refresh slot for job %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// releaseSlot frees the slot held by an execution.
+func (s *ExecutionService) releaseSlot(functionName string, id uuid.UUID) {
+	if err := s.coordinator.ReleaseSlot(context.Background(), functionName, id.String()); err != nil {
+		log.Errorf("Failed to release slot for job %s: %v", id, err)
+	}
+}
+
+// stillPending reports whether the execution with the given ID is still
+// pending. Errors are logged and reported as pending, as `Acquire` makes the
+// final decision anyway.
+func (s *ExecutionService) stillPending(id uuid.UUID) bool {
+	execution, err := s.repo.GetByID(id)
+	if err != nil {
+		log.Errorf("Failed to get job %s: %v", id, err)
+		return true
+	}
+	return execution != nil && execution.Status == models.ExecutionStatusPending
+}
+
+// waitForLock delays a pending job until the lock or slots of its function
+// are checked again. If the job cannot be delayed, the execution is marked as
+// failed.
+func (s *ExecutionService) waitForLock(payload *models.ExecutionWithTrigger) {
+	s.delayOrFail(payload, time.Now().Add(lockRetryDelay))
+}
+
+// delayOrFail delays a pending job until `runAt`. If the job cannot be
+// delayed, the execution is marked as failed.
+func (s *ExecutionService) delayOrFail(payload *models.ExecutionWithTrigger, runAt time.Time) {
+	if err := s.enqueueDelayed(payload, runAt); err != nil {
+		log.Errorf("Failed to delay job %s: %v", payload.Execution.ID, err)
+		if err := s.repo.UpdateStatus(payload.Execution.ID, models.ExecutionStatusFailed); err != nil {
+			log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+)
+
+// blockingFunction returns a function that reports each start on `started`
+// and then blocks until `release` is closed.
+func blockingFunction(started chan<- struct{}, release <-chan struct{}) models.ResultFunction {
+	return func(context.Context, *models.Trigger) (any, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil
+	}
+}
+
+func TestExecutionServiceConcurrencyMode(t *testing.T) {
+	tests := []struct {
+		mode models.ConcurrencyMode
+		// want is the status of the second execution once the first one
+		// holds the lock.
+		want models.ExecutionStatus
+		// delayed tells whether the second execution waits for the lock.
+		delayed bool
+	}{
+		{mode: models.ConcurrencySkip, want: models.ExecutionStatusSkipped},
+		{mode: models.ConcurrencyQueue, want: models.ExecutionStatusPending, delayed: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			e := newExecutionTest()
+			started, release := make(chan struct{}, 2), make(chan struct{})
+			e.register(blockingFunction(started, release), models.WithConcurrencyMode(tt.mode))
+			first, second := e.newJob(t, 1), e.newJob(t, 1)
+
+			done := e.start(first)
+			<-started
+			e.execute(t, second, time.Second)
+			if got := e.execution(t, second).Status; got != tt.want {
+				t.Fatalf("second execution: got status %s, want %s", got, tt.want)
+			}
+			if len(started) != 0 {
+				t.Fatal("second execution started while the first one holds the lock")
+			}
+
+			close(release)
+			waitFor(t, done, time.Second)
+			if got := e.execution(t, first).Status; got != models.ExecutionStatusCompleted {
+				t.Fatalf("first execution: got status %s, want %s", got, models.ExecutionStatusCompleted)
+			}
+			if !tt.delayed {
+				if delayed := e.delayed(); len(delayed) != 0 {
+					t.Fatalf("got %d delayed jobs, want none", len(delayed))
+				}
+				return
+			}
+
+			// The second execution runs once the lock is free.
+			job, runAt := e.delayedJob(t, second)
+			if wait := time.Until(runAt); wait > lockRetryDelay {
+				t.Fatalf("second execution is delayed by %s, want at most %s", wait, lockRetryDelay)
+			}
+			e.execute(t, job, time.Second)
+			if got := e.execution(t, second).Status; got != models.ExecutionStatusCompleted {
+				t.Fatalf("second execution: got status %s, want %s", got, models.ExecutionStatusCompleted)
+			}
+		})
+	}
+}
+
+func TestExecutionServiceMaxConcurrency(t *testing.T) {
+	e := newExecutionTest()
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	e.register(blockingFunction(started, release), models.WithMaxConcurrency(1))
+	first, second := e.newJob(t, 1), e.newJob(t, 1)
+
+	done := e.start(first)
+	<-started
+	// The second execution waits for the slot held by the first one.
+	e.execute(t, second, time.Second)
+	if got := e.execution(t, second).Status; got != models.ExecutionStatusPending {
+		t.Fatalf("second execution: got status %s, want %s", got, models.ExecutionStatusPending)
+	}
+	if len(started) != 0 {
+		t.Fatal("second execution started over the concurrency limit")
+	}
+	job, runAt := e.delayedJob(t, second)
+	if wait := time.Until(runAt); wait > lockRetryDelay {
+		t.Fatalf("second execution is delayed by %s, want at most %s", wait, lockRetryDelay)
+	}
+
+	close(release)
+	waitFor(t, done, time.Second)
+	// The slot is released along with the first execution.
+	e.execute(t, job, time.Second)
+	for _, job := range []string{first, second} {
+		if got := e.execution(t, job).Status; got != models.ExecutionStatusCompleted {
+			t.Fatalf("execution %s: got status %s, want %s", jobExecutionID(job), got, models.ExecutionStatusCompleted)
+		}
+	}
+}
+
+func TestExecutionServiceMaxConcurrencyBelowLimit(t *testing.T) {
+	e := newExecutionTest()
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	e.register(blockingFunction(started, release), models.WithMaxConcurrency(2))
+	first, second := e.newJob(t, 1), e.newJob(t, 1)
+
+	done := []<-chan struct{}{e.start(first), e.start(second)}
+	for range done {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("executions within the concurrency limit did not run at the same time")
+		}
+	}
+	close(release)
+	for _, done := range done {
+		waitFor(t, done, time.Second)
+	}
+}
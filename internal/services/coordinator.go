@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// Coordinator provides the primitives the `ExecutionService` uses to enforce
+// the limits of functions and to cancel executions across all instances
+// sharing it. Locks and slots are claimed on behalf of a holder, expire after
+// the given TTL unless refreshed, and are only refreshed or released by their
+// holder.
+type Coordinator interface {
+	// TryLock acquires the named lock for the holder, unless it is held
+	// already. It reports whether the lock has been acquired.
+	TryLock(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	// LockHolder returns the holder of the named lock, or an empty string if
+	// the lock is free.
+	LockHolder(ctx context.Context, name string) (string, error)
+	// RefreshLock extends the named lock if it is still held by the holder.
+	RefreshLock(ctx context.Context, name string, holder string, ttl time.Duration) error
+	// Unlock releases the named lock if it is still held by the holder.
+	Unlock(ctx context.Context, name string, holder string) error
+
+	// AcquireSlot claims one of the `limit` slots of the named semaphore for
+	// the holder. It reports whether a slot has been claimed, and whether
+	// the holder held one already, in which case none is claimed.
+	AcquireSlot(
+		ctx context.Context,
+		name string,
+		holder string,
+		limit int,
+		ttl time.Duration,
+	) (claimed bool, held bool, err error)
+	// RefreshSlot extends the slot of the holder in the named semaphore.
+	RefreshSlot(ctx context.Context, name string, holder string, ttl time.Duration) error
+	// ReleaseSlot frees the slot of the holder in the named semaphore.
+	ReleaseSlot(ctx context.Context, name string, holder string) error
+
+	// Throttle records a start of the holder under the given name, unless it
+	// would exceed one of the rate limits. Otherwise, nothing is recorded and
+	// it returns how long to wait until the start fits within the limits.
+	// The periods of the limits are distinct.
+	Throttle(ctx context.Context, name string, holder string, limits []models.RateLimit) (time.Duration, error)
+	// Unthrottle removes the start of the holder recorded under the given
+	// name, so that it no longer counts against the rate limits.
+	Unthrottle(ctx context.Context, name string, holder string, limits []models.RateLimit) error
+
+	// PublishCancellation asks every instance to cancel the execution with
+	// the given ID, should it be running there.
+	PublishCancellation(ctx context.Context, id uuid.UUID) error
+	// Cancellations returns the IDs of the executions to cancel, as
+	// published with `PublishCancellation`, until the provided context is
+	// done, at which point the channel is closed.
+	Cancellations(ctx context.Context) <-chan uuid.UUID
+}
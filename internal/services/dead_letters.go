@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+var (
+	// ErrDeadLetterNotFound is returned when an operation refers to a dead
+	// letter that is not in the dead-letter queue.
+	ErrDeadLetterNotFound = errors.New("the requested dead letter does not exist")
+	// ErrDeadLetterMalformed is returned when requeueing a dead letter whose
+	// job cannot be decoded.
+	ErrDeadLetterMalformed = errors.New("the dead letter holds a malformed job")
+)
+
+// deadLetter moves a job to the dead-letter queue. If the job belongs to a
+// known execution, the execution is marked as dead as well.
+func (s *ExecutionService) deadLetter(
+	job string,
+	executionID *uuid.UUID,
+	reason models.DeadLetterReason,
+	cause error,
+) {
+	entry := models.DeadLetter{
+		ID:          uuid.New(),
+		ExecutionID: executionID,
+		Reason:      reason,
+		Job:         job,
+		DeadAt:      time.Now().UTC(),
+	}
+	if executionID != nil {
+		entry.ID = *executionID
+	}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		log.Errorf("Failed to marshal dead letter %s: %v", entry.ID, err)
+		return
+	}
+	if err := s.queue.Bury(context.Background(), string(data)); err != nil {
+		log.Errorf("Failed to dead-letter job %s: %v", entry.ID, err)
+	}
+
+	if executionID != nil {
+		if err := s.repo.UpdateStatus(*executionID, models.ExecutionStatusDead); err != nil {
+			log.Errorf("Failed to update status for job %s: %v", *executionID, err)
+		}
+	}
+}
+
+// ListDeadLetters retrieves all dead letters, newest first.
+func (s *ExecutionService) ListDeadLetters() ([]*models.DeadLetter, error) {
+	entries, err := s.queue.DeadLetters(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := make([]*models.DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		var deadLetter models.DeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			log.Errorf("Failed to unmarshal dead letter: %v", err)
+			continue
+		}
+		deadLetters = append(deadLetters, &deadLetter)
+	}
+	return deadLetters, nil
+}
+
+// GetDeadLetter retrieves a single dead letter by its ID. It returns nil
+// without an error if no such dead letter exists.
+func (s *ExecutionService) GetDeadLetter(id uuid.UUID) (*models.DeadLetter, error) {
+	deadLetter, _, err := s.findDeadLetter(id)
+	return deadLetter, err
+}
+
+// RequeueDeadLetter removes a dead letter from the dead-letter queue and
+// enqueues its job again, starting over at the first attempt.
+//
+// It returns `ErrDeadLetterNotFound` if the dead letter does not exist,
+// `ErrDeadLetterMalformed` if its job cannot be decoded,
+// `ErrFunctionNotFound` if its function is (still) not registered, and
+// `ErrExecutionNotFound` if its execution has been deleted along with its
+// trigger. In these cases, the dead letter is left in place.
+func (s *ExecutionService) RequeueDeadLetter(id uuid.UUID) (*models.Execution, error) {
+	deadLetter, raw, err := s.findDeadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetter == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	var payload models.ExecutionWithTrigger
+	if err := json.Unmarshal([]byte(deadLetter.Job), &payload); err != nil {
+		return nil, ErrDeadLetterMalformed
+	}
+	if !s.HasFunction(payload.Trigger.FunctionName) {
+		return nil, ErrFunctionNotFound
+	}
+	execution, err := s.repo.GetByID(payload.Execution.ID)
+	if err != nil {
+		return nil, err
+	}
+	if execution == nil {
+		return nil, ErrExecutionNotFound
+	}
+
+	// Removing the entry first guarantees that concurrent requeues of the
+	// same dead letter enqueue its job only once.
+	removed, err := s.queue.RemoveDeadLetter(context.Background(), raw)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	payload.Execution.Status = models.ExecutionStatusPending
+	payload.Execution.Attempt = 1
+	payload.Execution.StartedAt = nil
+	payload.Execution.FinishedAt = nil
+	scheduled, err := s.repo.ScheduleRetry(payload.Execution.ID, payload.Execution.Attempt)
+	if err != nil || !scheduled {
+		// Put the dead letter back, as its job has not been enqueued.
+		if buryErr := s.queue.Bury(context.Background(), raw); buryErr != nil {
+			log.Errorf("Failed to restore dead letter %s: %v", id, buryErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrExecutionNotFound
+	}
+
+	if err := s.enqueue(&payload); err != nil {
+		return nil, err
+	}
+
+	return &payload.Execution, nil
+}
+
+// PurgeDeadLetters removes all dead letters from the dead-letter queue. The
+// corresponding executions keep their `Dead` status.
+func (s *ExecutionService) PurgeDeadLetters() error {
+	return s.queue.PurgeDeadLetters(context.Background())
+}
+
+// findDeadLetter looks up a dead letter by its ID. Along with the decoded
+// dead letter, it returns its raw entry in the dead-letter queue.
+func (s *ExecutionService) findDeadLetter(id uuid.UUID) (*models.DeadLetter, string, error) {
+	entries, err := s.queue.DeadLetters(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, entry := range entries {
+		var deadLetter models.DeadLetter
+		if err := json.Unmarshal([]byte(entry), &deadLetter); err != nil {
+			continue
+		}
+		if deadLetter.ID == id {
+			return &deadLetter, entry, nil
+		}
+	}
+	return nil, "", nil
+}
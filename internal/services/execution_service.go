@@ -5,207 +5,635 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Pelfox/quego/internal/repositories"
 	"github.com/Pelfox/quego/models"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
 	"github.com/labstack/gommon/log"
-	"github.com/redis/go-redis/v9"
 )
 
+// dequeueRetryDelay is how long a dispatcher waits before retrying after a
+// failed dequeue, so that an unavailable queue does not cause a busy loop.
+const dequeueRetryDelay = time.Second
+
 // ErrFunctionNotFound is returned when an attempt is made to process a trigger
 // whose target function has not been registered with the `ExecutionService`.
 var ErrFunctionNotFound = errors.New("the requested function is not registered")
 
+// ErrFunctionPanicked is wrapped by the error of an execution whose function
+// panicked. Such executions are treated like any other failure.
+var ErrFunctionPanicked = errors.New("the function panicked")
+
 // ExecutionService provides operations related to `Execution` entities. It
-// uses an `ExecutionRepository` for data persistence while serving as the main
-// access point for higher layers.
+// uses an `ExecutionRepository` for data persistence and a `Queue` to carry
+// jobs to its workers, while serving as the main access point for higher
+// layers.
 type ExecutionService struct {
-	redis     *redis.Client
-	repo      *repositories.ExecutionRepository
-	functions map[string]models.ExecFunction
-	workerSem chan struct{}
+	queue       Queue
+	coordinator Coordinator
+	repo        repositories.ExecutionRepository
+	functions   map[string]*registeredFunction
+	// pools holds the worker semaphores of the queues served by this
+	// instance, keyed by queue name.
+	pools map[string]chan struct{}
+	// workerID identifies this instance's workers in execution leases.
+	workerID string
+
+	// running holds the cancel functions of the executions currently running
+	// on this instance, keyed by execution ID.
+	running   map[uuid.UUID]context.CancelCauseFunc
+	runningMu sync.Mutex
+
+	// inFlight tracks the jobs dequeued by this instance that have not been
+	// acknowledged yet.
+	inFlight sync.WaitGroup
+	// stopDispatch stops the dispatchers and the background goroutines
+	// started by `StartWorkers`.
+	stopDispatch context.CancelFunc
+}
+
+// registeredFunction is a function along with the options it was registered
+// with.
+type registeredFunction struct {
+	exec    models.ResultFunction
+	options models.FunctionOptions
 }
 
 // NewExecutionService creates and returns a new `ExecutionService` instance
-// backed by the provided `ExecutionRepository` and a Redis client.
+// backed by the provided `ExecutionRepository`, `Queue` and `Coordinator`.
+// The instance serves the given queues, each with the given number of
+// workers; queues with no workers are not served.
 func NewExecutionService(
-	workersCount int,
-	redis *redis.Client,
-	repo *repositories.ExecutionRepository,
+	queues map[string]int,
+	queue Queue,
+	coordinator Coordinator,
+	repo repositories.ExecutionRepository,
 ) *ExecutionService {
+	pools := make(map[string]chan struct{}, len(queues))
+	for queue, workersCount := range queues {
+		if workersCount > 0 {
+			pools[queue] = make(chan struct{}, workersCount)
+		}
+	}
 	return &ExecutionService{
-		redis:     redis,
-		repo:      repo,
-		functions: make(map[string]models.ExecFunction),
-		workerSem: make(chan struct{}, workersCount),
+		queue:       queue,
+		coordinator: coordinator,
+		repo:        repo,
+		functions:   make(map[string]*registeredFunction),
+		pools:       pools,
+		workerID:    newWorkerID(),
+		running:     make(map[uuid.UUID]context.CancelCauseFunc),
 	}
 }
 
 // RegisterFunction adds a new `Function` to the service in order. Registered
-// functions can later be invoked or managed by the `ExecutionService`.
-func (s *ExecutionService) RegisterFunction(name string, f models.ExecFunction) {
-	s.functions[name] = f
+// functions can later be invoked or managed by the `ExecutionService`. The
+// given options configure how executions of the function are handled, e.g.
+// whether failed executions are retried.
+func (s *ExecutionService) RegisterFunction(name string, f models.ResultFunction, opts ...models.FunctionOption) {
+	var options models.FunctionOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Queue == "" {
+		options.Queue = models.DefaultQueue
+	}
+	options.RateLimits = validRateLimits(name, options.RateLimits)
+	s.functions[name] = &registeredFunction{exec: f, options: options}
 }
 
-// Process looks up and executes the function associated with call from the
-// given `Trigger` payload.
-//
-// If a matching function is found, its `Exec` method is invoked in a separate
-// goroutine, allowing the execution to run asynchronously. The method then
-// returns immediately with no error.
+// HasFunction reports whether a function with the given name has been
+// registered with the service.
+func (s *ExecutionService) HasFunction(name string) bool {
+	_, ok := s.functions[name]
+	return ok
+}
+
+// ListFunctions describes every registered function, sorted by name, along
+// with the number of its executions that are pending or running.
+func (s *ExecutionService) ListFunctions() ([]*models.FunctionInfo, error) {
+	counts, err := s.repo.CountByFunction([]models.ExecutionStatus{
+		models.ExecutionStatusPending,
+		models.ExecutionStatusRunning,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	functions := make([]*models.FunctionInfo, 0, len(s.functions))
+	for name, function := range s.functions {
+		options := function.options
+		info := &models.FunctionInfo{
+			Name:          name,
+			Description:   options.Description,
+			Tags:          append([]string{}, options.Tags...),
+			PayloadSchema: options.PayloadSchema,
+			Queue:         options.Queue,
+			Retry: models.RetryInfo{
+				MaxAttempts:    max(options.Retry.MaxAttempts, 1),
+				InitialBackoff: options.Retry.InitialBackoff.String(),
+				Multiplier:     max(options.Retry.Multiplier, 1),
+				Jitter:         options.Retry.Jitter,
+			},
+			Concurrency:    options.Concurrency,
+			MaxConcurrency: options.MaxConcurrency,
+			Pending:        counts[name][models.ExecutionStatusPending],
+			Running:        counts[name][models.ExecutionStatusRunning],
+		}
+		if options.Retry.MaxBackoff > 0 {
+			info.Retry.MaxBackoff = options.Retry.MaxBackoff.String()
+		}
+		if options.Timeout > 0 {
+			info.Timeout = options.Timeout.String()
+		}
+		if info.Concurrency == "" {
+			info.Concurrency = models.ConcurrencyAllow
+		}
+		functions = append(functions, info)
+	}
+
+	slices.SortFunc(functions, func(a, b *models.FunctionInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return functions, nil
+}
+
+// NewExecution returns the first execution of the given `Trigger`, which is
+// pending and becomes runnable at `runAt`. A zero or past `runAt` makes the
+// execution runnable right away. The execution is meant to be persisted along
+// with the trigger through `TriggerService.Create`, then enqueued with
+// `QueueExecution`.
 //
-// If no function matches the trigger's request name, the method returns the
+// If no function matches the trigger's function name, the method returns the
 // `ErrFunctionNotFound` error.
-func (s *ExecutionService) Process(trigger *models.Trigger) (*models.Execution, error) {
-	_, ok := s.functions[trigger.FunctionName]
-	if !ok {
+func (s *ExecutionService) NewExecution(trigger *models.Trigger, runAt time.Time) (*models.Execution, error) {
+	if !s.HasFunction(trigger.FunctionName) {
 		return nil, ErrFunctionNotFound
 	}
 
-	payload := &models.Execution{
-		ID:        uuid.New(),
-		Status:    models.ExecutionStatusPending,
-		TriggerID: *trigger.ID,
+	execution := &models.Execution{
+		ID:       uuid.New(),
+		Status:   models.ExecutionStatusPending,
+		Attempt:  1,
+		Priority: trigger.Priority,
 	}
-	if err := s.repo.Create(payload); err != nil {
-		return nil, err
+	if runAt.After(time.Now()) {
+		scheduledAt := runAt.UTC()
+		execution.ScheduledAt = &scheduledAt
 	}
+	return execution, nil
+}
 
+// QueueExecution enqueues a persisted execution of the given `Trigger`, so
+// that it is run by the workers. An execution scheduled later is kept in the
+// delayed set until then.
+func (s *ExecutionService) QueueExecution(trigger *models.Trigger, execution *models.Execution) error {
 	model := models.ExecutionWithTrigger{
-		Execution: *payload,
+		Execution: *execution,
 		Trigger:   *trigger,
 	}
+	if execution.ScheduledAt != nil && execution.ScheduledAt.After(time.Now()) {
+		return s.enqueueDelayed(&model, *execution.ScheduledAt)
+	}
+	return s.enqueue(&model)
+}
 
-	data, err := json.Marshal(&model)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal trigger: %w", err)
+// StartWorkers launches a dispatcher goroutine for every queue served by this
+// instance, which continuously listens for jobs on the queue and hands them to
+// the queue's pool of worker goroutines.
+//
+// Dequeued jobs are held by this instance and only acknowledged once their
+// outcome has been recorded. If the instance dies in the meantime, a shared
+// `Queue` hands the jobs to another instance.
+//
+// The number of concurrent workers of a queue is limited by its channel in
+// `pools`, which acts as a semaphore to control concurrency. The dispatcher
+// acquires a slot before dequeuing, so a job is only taken off the queue once
+// a worker is free to run it. Each job is then processed in its own
+// goroutine, which releases the slot once the job has finished, regardless of
+// the outcome.
+//
+// Alongside the dispatchers, the `Queue` is started for the served queues, so
+// that delayed jobs (e.g. retries waiting for their backoff) become runnable
+// once due. Background goroutines requeue running executions whose lease has
+// expired because their worker stopped sending heartbeats, and pending
+// executions whose job is missing from their queue.
+//
+// The method runs indefinitely until the provided context is canceled or
+// `Stop` is called, at which point it stops dequeuing. Executions that are
+// already running are not affected by the context; use `Stop` to wait for
+// them.
+func (s *ExecutionService) StartWorkers(ctx context.Context) {
+	ctx, s.stopDispatch = context.WithCancel(ctx)
+
+	for name, function := range s.functions {
+		if _, ok := s.pools[function.options.Queue]; !ok {
+			log.Warnf("Function %s is assigned to queue %s, which this instance does not serve", name, function.options.Queue)
+		}
 	}
 
-	if err := s.redis.LPush(context.Background(), "quego:queue", data).Err(); err != nil {
-		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	queues := make([]string, 0, len(s.pools))
+	for queue := range s.pools {
+		queues = append(queues, queue)
 	}
+	s.queue.Start(ctx, queues)
 
-	return payload, nil
+	go s.listenForCancellations(ctx)
+	go s.reapExpiredLeases(ctx)
+	go s.restorePendingJobs(ctx)
+	for queue, sem := range s.pools {
+		go s.dispatch(ctx, queue, sem)
+	}
 }
 
-// StartWorkers launches worker goroutines that continuously listen for and
-// process jobs from the Redis queue. Each worker retrieves jobs using a
-// blocking pop operation, ensuring efficient resource usage.
-//
-// The number of concurrent workers is limited by the `workerSem` channel,
-// which acts as a semaphore to control concurrency. Each worker processes
-// jobs by looking up the corresponding function and executing it. The status
-// of each job is updated in the repository based on the execution outcome.
-//
-// The method runs indefinitely until the provided context is canceled, at
-// which point it gracefully exits.
-func (s *ExecutionService) StartWorkers(ctx context.Context) {
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
+// dispatch dequeues the jobs of the given queue and runs each of them in its
+// own goroutine, as long as a slot of the semaphore is free, until the
+// provided context is canceled.
+func (s *ExecutionService) dispatch(ctx context.Context, queue string, sem chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+
+		job, err := s.queue.Dequeue(ctx, queue)
+		if err != nil {
+			<-sem
+			if ctx.Err() != nil {
 				return
-			default:
-				result, err := s.redis.BLPop(ctx, 0, "quego:queue").Result()
-				if err != nil {
-					log.Errorf("Failed to dequeue job: %v", err)
-					continue
-				}
-
-				var payload models.ExecutionWithTrigger
-				if err := json.Unmarshal([]byte(result[1]), &payload); err != nil {
-					log.Errorf("Failed to unmarshal job payload: %v", err)
-					continue
-				}
-
-				f, ok := s.functions[payload.Trigger.FunctionName]
-				if !ok {
-					log.Errorf("Function not found: %s", payload.Trigger.FunctionName)
-					continue
-				}
-
-				s.workerSem <- struct{}{}
-				if err := s.repo.UpdateStatus(payload.Execution.ID, models.ExecutionStatusRunning); err != nil {
-					<-s.workerSem
-					log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
-					continue
-				}
-
-				if err := f(&payload.Trigger); err != nil {
-					<-s.workerSem
-					log.Errorf("Function execution failed for job %s: %v", payload.Execution.ID, err)
-					if err := s.repo.UpdateStatus(payload.Execution.ID, models.ExecutionStatusFailed); err != nil {
-						log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
-					}
-					continue
-				}
-				if err := s.repo.UpdateStatus(payload.Execution.ID, models.ExecutionStatusCompleted); err != nil {
-					<-s.workerSem
-					log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
-					continue
-				}
-				<-s.workerSem
 			}
+			log.Errorf("Failed to dequeue job from queue %s: %v", queue, err)
+			time.Sleep(dequeueRetryDelay)
+			continue
 		}
-	}()
+
+		s.inFlight.Add(1)
+		go func() {
+			defer s.inFlight.Done()
+			defer func() { <-sem }()
+			s.execute(context.WithoutCancel(ctx), queue, job)
+			if err := s.queue.Ack(context.Background(), queue, job); err != nil {
+				log.Errorf("Failed to acknowledge job: %v", err)
+			}
+		}()
+	}
 }
 
-// GetByID retrieves an `Execution` entity by its unique identifier. It
-// delegates the lookup to the underlying repository.
-func (s *ExecutionService) GetByID(id uuid.UUID) (*models.Execution, error) {
-	return s.repo.GetByID(id)
+// Stop gracefully stops the workers started by `StartWorkers`. It stops
+// dequeuing and waits for the running executions to finish. If the provided
+// context is done before that, the remaining executions are cancelled with
+// `ErrShuttingDown` and requeued, so that another instance (or this one,
+// after a restart) runs them again.
+//
+// Finally, every job still held by this instance is returned to its queue.
+// Stop returns the context's error if the executions had to be cancelled.
+func (s *ExecutionService) Stop(ctx context.Context) error {
+	if s.stopDispatch == nil {
+		return nil
+	}
+	s.stopDispatch()
+
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Warnf("Shutdown deadline exceeded, cancelling running executions")
+		s.cancelAllRunning(ErrShuttingDown)
+		// Cancelled executions return right away, as functions ignoring
+		// their context are abandoned.
+		<-done
+	}
+
+	if releaseErr := s.queue.Release(context.Background()); releaseErr != nil {
+		log.Errorf("Failed to release jobs: %v", releaseErr)
+	}
+	return err
 }
 
-// ListAllTriggers retrieves all `Execution` entities from the underlying repository.
-func (s *ExecutionService) ListAllTriggers() ([]*models.ExecutionWithTrigger, error) {
-	return s.repo.ListAll()
+// execute runs a single job dequeued from the given queue: it looks up the
+// corresponding function, executes it and records the outcome in the
+// repository. The function's context is derived from `ctx`.
+func (s *ExecutionService) execute(ctx context.Context, queue string, job string) {
+	var payload models.ExecutionWithTrigger
+	if err := json.Unmarshal([]byte(job), &payload); err != nil {
+		log.Errorf("Failed to unmarshal job payload: %v", err)
+		s.deadLetter(job, nil, models.DeadLetterReasonMalformedJob, err)
+		return
+	}
+
+	f, ok := s.functions[payload.Trigger.FunctionName]
+	if !ok {
+		log.Errorf("Function not found: %s", payload.Trigger.FunctionName)
+		s.deadLetter(job, &payload.Execution.ID, models.DeadLetterReasonFunctionNotFound, nil)
+		return
+	}
+
+	lock, ok := s.acquireLock(&payload, f.options.Concurrency)
+	if !ok {
+		return
+	}
+	if lock != "" {
+		defer s.releaseLock(lock, payload.Execution.ID)
+	}
+	slot, ok := s.acquireSlot(&payload, f.options.MaxConcurrency)
+	if !ok {
+		return
+	}
+	if slot {
+		defer s.releaseSlot(payload.Trigger.FunctionName, payload.Execution.ID)
+	}
+
+	if !s.checkRateLimits(&payload, f.options.RateLimits) {
+		return
+	}
+
+	// Only a pending execution may start. This skips jobs that have been
+	// cancelled while queued, as well as duplicate entries of the same job.
+	started, err := s.repo.Acquire(payload.Execution.ID, s.workerID, time.Now().UTC().Add(leaseDuration))
+	if err != nil {
+		log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
+		s.forgetStart(&payload, f.options.RateLimits)
+		return
+	}
+	if !started {
+		log.Infof("Skipping job %s as it is no longer pending", payload.Execution.ID)
+		s.forgetStart(&payload, f.options.RateLimits)
+		return
+	}
+
+	// Jobs enqueued before attempts were tracked carry no attempt number.
+	payload.Execution.Attempt = max(payload.Execution.Attempt, 1)
+
+	execCtx := models.NewExecutionContext(ctx, payload.Execution.ID, payload.Execution.Attempt)
+	execCtx, cancel := context.WithCancelCause(execCtx)
+	defer cancel(nil)
+	s.trackRunning(payload.Execution.ID, cancel)
+	defer s.untrackRunning(payload.Execution.ID)
+	go s.heartbeat(execCtx, payload.Execution.ID, cancel)
+	if lock != "" {
+		go s.holdLock(execCtx, lock, payload.Execution.ID)
+	}
+	if slot {
+		go s.holdSlot(execCtx, payload.Trigger.FunctionName, payload.Execution.ID)
+	}
+
+	if f.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(execCtx, f.options.Timeout)
+		defer cancel()
+	}
+
+	status := models.ExecutionStatusCompleted
+	result, err := run(execCtx, f.exec, &payload.Trigger)
+	if err != nil {
+		status = models.ExecutionStatusFailed
+		switch {
+		case errors.Is(context.Cause(execCtx), ErrShuttingDown):
+			log.Warnf("Job %s interrupted by shutdown, requeueing it", payload.Execution.ID)
+			s.requeue(queue, job, payload.Execution.ID)
+			return
+		case errors.Is(context.Cause(execCtx), ErrLeaseLost):
+			// The execution has been requeued and belongs to another worker.
+			log.Warnf("Job %s stopped after losing its lease", payload.Execution.ID)
+			return
+		case errors.Is(context.Cause(execCtx), ErrExecutionCancelled):
+			// Cancelled executions are never retried.
+			log.Infof("Job %s has been cancelled", payload.Execution.ID)
+			s.recordOutcome(payload.Execution.ID, nil, err)
+			if err := s.repo.UpdateStatus(payload.Execution.ID, models.ExecutionStatusCancelled); err != nil {
+				log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
+			}
+			return
+		case errors.Is(execCtx.Err(), context.DeadlineExceeded):
+			status = models.ExecutionStatusTimedOut
+			err = fmt.Errorf("execution timed out after %s: %w", f.options.Timeout, err)
+		}
+
+		log.Errorf(
+			"Function execution failed for job %s (attempt %d): %v",
+			payload.Execution.ID, payload.Execution.Attempt, err,
+		)
+		s.recordOutcome(payload.Execution.ID, nil, err)
+		switch {
+		case payload.Execution.Attempt < f.options.Retry.MaxAttempts:
+			s.retry(&payload, f.options.Retry.Backoff(payload.Execution.Attempt))
+			return
+		case f.options.Retry.MaxAttempts > 1:
+			// Executions that are never retried keep their failure status
+			// instead, as they have no retries to exhaust.
+			s.deadLetter(job, &payload.Execution.ID, models.DeadLetterReasonRetriesExhausted, err)
+			return
+		}
+	} else {
+		s.recordOutcome(payload.Execution.ID, result, nil)
+	}
+	if err := s.repo.UpdateStatus(payload.Execution.ID, status); err != nil {
+		log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
+	}
 }
 
-// RequeueStaled identifies executions that are in a `Running` state but have
-// not updated for a prolonged period, indicating they may have stalled or
-// crashed. It re-enqueues these executions back into the Redis queue for
-// reprocessing and updates their status to `Pending`.
+// run invokes the function and waits until it returns or its context is done,
+// whichever happens first. A function that ignores its context and keeps
+// running past that point is abandoned, so that it does not pin a worker.
 //
-// The method logs any errors encountered during the re-enqueueing process but
-// continues processing other staled executions.
-func (s *ExecutionService) RequeueStaled() error {
-	staled, err := s.repo.GetStaled()
-	if err != nil {
-		return err
+// A panic in the function is recovered and returned as an error wrapping
+// `ErrFunctionPanicked`, annotated with the stack trace of the panic.
+func run(ctx context.Context, f models.ResultFunction, trigger *models.Trigger) (any, error) {
+	type outcome struct {
+		result any
+		err    error
 	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- outcome{err: &models.StackTraceError{
+					Err:        fmt.Errorf("%w: %v", ErrFunctionPanicked, recovered),
+					StackTrace: string(debug.Stack()),
+				}}
+			}
+		}()
+		result, err := f(ctx, trigger)
+		done <- outcome{result: result, err: err}
+	}()
 
-	for _, exec := range staled {
-		data, err := json.Marshal(&models.ExecutionWithTrigger{
-			Execution: models.Execution{
-				ID:         exec.Execution.ID,
-				Status:     exec.Execution.Status,
-				TriggerID:  exec.TriggerID,
-				StartedAt:  exec.StartedAt,
-				FinishedAt: exec.FinishedAt,
-			},
-			Trigger: models.Trigger{
-				TriggerType:  exec.Trigger.TriggerType,
-				FunctionName: exec.Trigger.FunctionName,
-				Payload:      exec.Trigger.Payload,
-				ID:           exec.Trigger.ID,
-			},
-		})
-		if err != nil {
-			log.Errorf("Failed to marshal staled execution %s: %v", exec.Execution.ID, err)
-			continue
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		// Prefer the function's own result if it returned in the meantime.
+		select {
+		case o := <-done:
+			return o.result, o.err
+		default:
+			return nil, ctx.Err()
 		}
+	}
+}
 
-		if err := s.redis.LPush(context.Background(), "quego:queue", data).Err(); err != nil {
-			log.Errorf("Failed to re-enqueue staled execution %s: %v", exec.Execution.ID, err)
-			continue
+// recordOutcome stores the outcome of an attempt on its execution: the
+// JSON-encoded result if it succeeded, or the error message and stack trace
+// if it failed. A result that cannot be encoded is not stored, but does not
+// fail the execution, as its function has already succeeded.
+func (s *ExecutionService) recordOutcome(id uuid.UUID, result any, execErr error) {
+	var (
+		encoded      *types.JSONText
+		errorMessage *string
+		stackTrace   *string
+	)
+
+	if execErr != nil {
+		message := execErr.Error()
+		errorMessage = &message
+		if trace := models.StackTraceOf(execErr); trace != "" {
+			stackTrace = &trace
 		}
+	} else if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			log.Errorf("Failed to encode result of job %s: %v", id, err)
+			message := fmt.Sprintf("failed to encode result: %v", err)
+			errorMessage = &message
+		} else {
+			text := types.JSONText(data)
+			encoded = &text
+		}
+	}
 
-		if err := s.repo.UpdateStatus(exec.Execution.ID, models.ExecutionStatusPending); err != nil {
-			log.Errorf("Failed to update status for staled execution %s: %v", exec.Execution.ID, err)
-			continue
+	if err := s.repo.SaveOutcome(id, encoded, errorMessage, stackTrace); err != nil {
+		log.Errorf("Failed to save outcome of job %s: %v", id, err)
+	}
+}
+
+// requeue returns an interrupted job to the front of its queue, keeping its
+// attempt number. If that fails, the execution stays running and is recovered
+// once its lease expires.
+func (s *ExecutionService) requeue(queue string, job string, id uuid.UUID) {
+	if err := s.repo.UpdateStatus(id, models.ExecutionStatusPending); err != nil {
+		log.Errorf("Failed to update status for job %s: %v", id, err)
+		return
+	}
+	if err := s.queue.Nack(context.Background(), queue, job); err != nil {
+		log.Errorf("Failed to requeue job %s: %v", id, err)
+	}
+}
+
+// retry schedules the next attempt of a failed job after the given backoff.
+// If the job cannot be rescheduled, the execution is marked as failed.
+func (s *ExecutionService) retry(payload *models.ExecutionWithTrigger, backoff time.Duration) {
+	payload.Execution.Attempt++
+	payload.Execution.Status = models.ExecutionStatusPending
+
+	scheduled, err := s.repo.ScheduleRetry(payload.Execution.ID, payload.Execution.Attempt)
+	if err == nil && !scheduled {
+		log.Warnf("Not retrying job %s as its execution has been deleted", payload.Execution.ID)
+		return
+	}
+	if err == nil {
+		err = s.enqueueDelayed(payload, time.Now().Add(backoff))
+	}
+	if err != nil {
+		log.Errorf("Failed to schedule retry for job %s: %v", payload.Execution.ID, err)
+		if err := s.repo.UpdateStatus(payload.Execution.ID, models.ExecutionStatusFailed); err != nil {
+			log.Errorf("Failed to update status for job %s: %v", payload.Execution.ID, err)
 		}
 	}
+}
+
+// queueOf returns the name of the queue the given function is assigned to.
+// Functions that are not registered with this instance are assigned to the
+// default queue, where they are dead-lettered unless another instance knows
+// them.
+func (s *ExecutionService) queueOf(functionName string) string {
+	if f, ok := s.functions[functionName]; ok {
+		return f.options.Queue
+	}
+	return models.DefaultQueue
+}
+
+// queueNames returns the names of all queues known to this instance: those it
+// serves and those its functions are assigned to.
+func (s *ExecutionService) queueNames() []string {
+	known := make(map[string]bool, len(s.pools))
+	for queue := range s.pools {
+		known[queue] = true
+	}
+	for _, f := range s.functions {
+		known[f.options.Queue] = true
+	}
+
+	names := make([]string, 0, len(known))
+	for queue := range known {
+		names = append(names, queue)
+	}
+	return names
+}
+
+// enqueue adds a job to the queue of its function.
+func (s *ExecutionService) enqueue(payload *models.ExecutionWithTrigger) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return s.queue.Enqueue(context.Background(), s.queueOf(payload.Trigger.FunctionName), string(data))
+}
+
+// enqueueDelayed adds a job to the queue of its function once `runAt` has
+// passed.
+func (s *ExecutionService) enqueueDelayed(payload *models.ExecutionWithTrigger, runAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return s.queue.Delay(context.Background(), s.queueOf(payload.Trigger.FunctionName), string(data), runAt)
+}
 
-	return nil
+// GetByID retrieves an `Execution` entity by its unique identifier. It
+// delegates the lookup to the underlying repository.
+func (s *ExecutionService) GetByID(id uuid.UUID) (*models.Execution, error) {
+	return s.repo.GetByID(id)
+}
+
+// GetLatestByTriggerID retrieves the most recently created `Execution` of the
+// given trigger. It returns nil without an error if the trigger has no
+// executions.
+func (s *ExecutionService) GetLatestByTriggerID(triggerID uuid.UUID) (*models.Execution, error) {
+	return s.repo.GetLatestByTriggerID(triggerID)
+}
+
+// ListExecutions retrieves a page of the `Execution` entities matching the
+// filter, newest first, of at most `filter.Limit` executions. It also returns
+// the cursor to pass as `filter.After` to retrieve the next page, or nil if
+// there are no more executions.
+func (s *ExecutionService) ListExecutions(
+	filter repositories.ExecutionFilter,
+) ([]*models.ExecutionWithTrigger, *repositories.Cursor, error) {
+	limit := filter.Limit
+	if limit > 0 {
+		// Retrieve one more execution to tell whether there is a next page.
+		filter.Limit++
+	}
+	executions, err := s.repo.List(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if limit <= 0 || len(executions) <= limit {
+		return executions, nil, nil
+	}
+	executions = executions[:limit]
+	return executions, repositories.ExecutionCursor(&executions[limit-1].Execution), nil
 }
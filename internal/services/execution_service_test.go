@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// executionTest holds an `ExecutionService` backed by in-memory components.
+type executionTest struct {
+	service *ExecutionService
+	queue   *MemoryQueue
+	store   *repositories.MemoryStore
+}
+
+// newExecutionTest returns an `ExecutionService` serving the test queue with
+// a single worker.
+func newExecutionTest() *executionTest {
+	queue, store := NewMemoryQueue(), repositories.NewMemoryStore()
+	return &executionTest{
+		service: NewExecutionService(
+			map[string]int{testQueue: 1},
+			queue,
+			NewMemoryCoordinator(),
+			store.Executions(),
+		),
+		queue: queue,
+		store: store,
+	}
+}
+
+// register registers a function named "fn" on the test queue.
+func (e *executionTest) register(f models.ResultFunction, opts ...models.FunctionOption) {
+	e.service.RegisterFunction("fn", f, append(opts, models.WithQueue(testQueue))...)
+}
+
+// newJob stores a pending execution of "fn" at the given attempt, enqueues its
+// job and dequeues it again, as a worker would before executing it.
+func (e *executionTest) newJob(t *testing.T, attempt int) string {
+	t.Helper()
+	triggerID, executionID := uuid.New(), uuid.New()
+	trigger := &models.Trigger{
+		ID:           &triggerID,
+		TriggerType:  models.TriggerTypeEvent,
+		FunctionName: "fn",
+		Payload:      "{}",
+	}
+	execution := &models.Execution{
+		ID:        executionID,
+		Status:    models.ExecutionStatusPending,
+		TriggerID: triggerID,
+		Attempt:   attempt,
+	}
+	if _, err := e.store.Triggers().CreateWithExecution(trigger, execution, 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(models.ExecutionWithTrigger{Execution: *execution, Trigger: *trigger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.queue.Enqueue(context.Background(), testQueue, string(data)); err != nil {
+		t.Fatal(err)
+	}
+	return dequeueWithin(t, e.queue, time.Second)
+}
+
+// start runs the given job in the background. The returned channel is closed
+// once it returns.
+func (e *executionTest) start(job string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.service.execute(context.Background(), testQueue, job)
+	}()
+	return done
+}
+
+// execute runs the given job, failing the test unless it returns within the
+// given timeout.
+func (e *executionTest) execute(t *testing.T, job string, timeout time.Duration) {
+	t.Helper()
+	waitFor(t, e.start(job), timeout)
+}
+
+// waitFor fails the test unless the given channel is closed within the given
+// timeout.
+func waitFor(t *testing.T, done <-chan struct{}, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("not done within %s", timeout)
+	}
+}
+
+// execution returns the execution of the given job.
+func (e *executionTest) execution(t *testing.T, job string) *models.Execution {
+	t.Helper()
+	execution, err := e.store.Executions().GetByID(jobExecutionID(job))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execution == nil {
+		t.Fatal("execution not found")
+	}
+	return execution
+}
+
+// delayed returns the delayed jobs of the test queue along with their due
+// times.
+func (e *executionTest) delayed() map[string]time.Time {
+	e.queue.mu.Lock()
+	defer e.queue.mu.Unlock()
+	delayed := make(map[string]time.Time)
+	for _, job := range e.queue.delayed[testQueue] {
+		delayed[job.job] = time.UnixMilli(int64(job.score))
+	}
+	return delayed
+}
+
+// delayedJob returns the delayed job of the given job's execution along with
+// its due time, failing the test unless there is exactly one.
+func (e *executionTest) delayedJob(t *testing.T, job string) (string, time.Time) {
+	t.Helper()
+	var (
+		found string
+		runAt time.Time
+		count int
+	)
+	for delayed, at := range e.delayed() {
+		if jobExecutionID(delayed) == jobExecutionID(job) {
+			found, runAt = delayed, at
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("got %d delayed jobs of execution %s, want 1", count, jobExecutionID(job))
+	}
+	return found, runAt
+}
+
+// deadLetters returns the entries of the dead-letter queue.
+func (e *executionTest) deadLetters(t *testing.T) []models.DeadLetter {
+	t.Helper()
+	entries, err := e.queue.DeadLetters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadLetters := make([]models.DeadLetter, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal([]byte(entry), &deadLetters[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return deadLetters
+}
+
+func TestExecutionServiceExecute(t *testing.T) {
+	failure := errors.New("failure")
+	tests := []struct {
+		name    string
+		attempt int
+		// function returns the function under test, which may act on the
+		// service running it.
+		function func(e *executionTest) models.ResultFunction
+		options  []models.FunctionOption
+		// check inspects the outcome of the job.
+		check func(t *testing.T, e *executionTest, job string)
+	}{
+		{
+			name:    "completed",
+			attempt: 1,
+			function: func(*executionTest) models.ResultFunction {
+				return func(context.Context, *models.Trigger) (any, error) { return "done", nil }
+			},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusCompleted {
+					t.Fatalf("got status %s, want %s", execution.Status, models.ExecutionStatusCompleted)
+				}
+				if execution.Result == nil || string(*execution.Result) != `"done"` {
+					t.Fatalf("got result %v, want %q", execution.Result, `"done"`)
+				}
+			},
+		},
+		{
+			name:    "retried",
+			attempt: 1,
+			function: func(*executionTest) models.ResultFunction {
+				return func(context.Context, *models.Trigger) (any, error) { return nil, failure }
+			},
+			options: []models.FunctionOption{models.WithRetryPolicy(models.RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Minute,
+			})},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusPending || execution.Attempt != 2 {
+					t.Fatalf("got status %s at attempt %d, want %s at attempt 2",
+						execution.Status, execution.Attempt, models.ExecutionStatusPending)
+				}
+				if execution.Error == nil || *execution.Error != failure.Error() {
+					t.Fatalf("got error %v, want %q", execution.Error, failure)
+				}
+
+				delayed := e.delayed()
+				if len(delayed) != 1 {
+					t.Fatalf("got %d delayed jobs, want 1", len(delayed))
+				}
+				for retry, runAt := range delayed {
+					var payload models.ExecutionWithTrigger
+					if err := json.Unmarshal([]byte(retry), &payload); err != nil {
+						t.Fatal(err)
+					}
+					if payload.Execution.ID != execution.ID || payload.Execution.Attempt != 2 {
+						t.Fatalf("got retry of execution %s at attempt %d, want execution %s at attempt 2",
+							payload.Execution.ID, payload.Execution.Attempt, execution.ID)
+					}
+					if wait := time.Until(runAt); wait < 50*time.Second || wait > time.Minute {
+						t.Fatalf("retry is due in %s, want about a minute", wait)
+					}
+				}
+			},
+		},
+		{
+			name:    "retries exhausted",
+			attempt: 3,
+			function: func(*executionTest) models.ResultFunction {
+				return func(context.Context, *models.Trigger) (any, error) { return nil, failure }
+			},
+			options: []models.FunctionOption{models.WithRetryPolicy(models.RetryPolicy{MaxAttempts: 3})},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusDead {
+					t.Fatalf("got status %s, want %s", execution.Status, models.ExecutionStatusDead)
+				}
+				if delayed := e.delayed(); len(delayed) != 0 {
+					t.Fatalf("got %d delayed jobs, want none", len(delayed))
+				}
+				deadLetters := e.deadLetters(t)
+				if len(deadLetters) != 1 {
+					t.Fatalf("got %d dead letters, want 1", len(deadLetters))
+				}
+				if deadLetters[0].ID != execution.ID ||
+					deadLetters[0].Reason != models.DeadLetterReasonRetriesExhausted ||
+					deadLetters[0].Error != failure.Error() {
+					t.Fatalf("got dead letter %+v, want execution %s with reason %s",
+						deadLetters[0], execution.ID, models.DeadLetterReasonRetriesExhausted)
+				}
+			},
+		},
+		{
+			name:    "failed without retries",
+			attempt: 1,
+			function: func(*executionTest) models.ResultFunction {
+				return func(context.Context, *models.Trigger) (any, error) { return nil, failure }
+			},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusFailed {
+					t.Fatalf("got status %s, want %s", execution.Status, models.ExecutionStatusFailed)
+				}
+				// A single allowed attempt has no retries to exhaust.
+				if deadLetters := e.deadLetters(t); len(deadLetters) != 0 {
+					t.Fatalf("got %d dead letters, want none", len(deadLetters))
+				}
+			},
+		},
+		{
+			name:    "timed out",
+			attempt: 1,
+			function: func(*executionTest) models.ResultFunction {
+				return func(ctx context.Context, _ *models.Trigger) (any, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+			},
+			options: []models.FunctionOption{models.WithTimeout(10 * time.Millisecond)},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusTimedOut {
+					t.Fatalf("got status %s, want %s", execution.Status, models.ExecutionStatusTimedOut)
+				}
+				if execution.Error == nil || !strings.Contains(*execution.Error, "timed out after 10ms") {
+					t.Fatalf("got error %v, want a timeout", execution.Error)
+				}
+			},
+		},
+		{
+			name:    "cancelled",
+			attempt: 1,
+			function: func(e *executionTest) models.ResultFunction {
+				return func(ctx context.Context, _ *models.Trigger) (any, error) {
+					id, _ := models.ExecutionIDFromContext(ctx)
+					if _, err := e.service.CancelExecution(id); err != nil {
+						return nil, err
+					}
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+			},
+			options: []models.FunctionOption{models.WithRetryPolicy(models.RetryPolicy{MaxAttempts: 3})},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusCancelled {
+					t.Fatalf("got status %s, want %s", execution.Status, models.ExecutionStatusCancelled)
+				}
+				// Cancelled executions are never retried.
+				if delayed := e.delayed(); len(delayed) != 0 {
+					t.Fatalf("got %d delayed jobs, want none", len(delayed))
+				}
+			},
+		},
+		{
+			name:    "interrupted by shutdown",
+			attempt: 2,
+			function: func(e *executionTest) models.ResultFunction {
+				return func(ctx context.Context, _ *models.Trigger) (any, error) {
+					e.service.cancelAllRunning(ErrShuttingDown)
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+			},
+			options: []models.FunctionOption{models.WithRetryPolicy(models.RetryPolicy{MaxAttempts: 3})},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusPending || execution.Attempt != 2 {
+					t.Fatalf("got status %s at attempt %d, want %s at attempt 2",
+						execution.Status, execution.Attempt, models.ExecutionStatusPending)
+				}
+				// The job is requeued as is, rather than retried.
+				expectJobs(t, e.queue, job)
+				if delayed := e.delayed(); len(delayed) != 0 {
+					t.Fatalf("got %d delayed jobs, want none", len(delayed))
+				}
+			},
+		},
+		{
+			name:    "panicked",
+			attempt: 1,
+			function: func(*executionTest) models.ResultFunction {
+				return func(context.Context, *models.Trigger) (any, error) { panic("boom") }
+			},
+			check: func(t *testing.T, e *executionTest, job string) {
+				execution := e.execution(t, job)
+				if execution.Status != models.ExecutionStatusFailed {
+					t.Fatalf("got status %s, want %s", execution.Status, models.ExecutionStatusFailed)
+				}
+				if execution.Error == nil || !strings.Contains(*execution.Error, ErrFunctionPanicked.Error()+": boom") {
+					t.Fatalf("got error %v, want a panic", execution.Error)
+				}
+				if execution.StackTrace == nil || !strings.Contains(*execution.StackTrace, "panic") {
+					t.Fatalf("got stack trace %v, want the stack of the panic", execution.StackTrace)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExecutionTest()
+			e.register(tt.function(e), tt.options...)
+			job := e.newJob(t, tt.attempt)
+			e.execute(t, job, time.Second)
+			tt.check(t, e, job)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// leaseDuration is how long a worker may run an execution without
+	// renewing its lease before the execution is considered abandoned.
+	leaseDuration = 30 * time.Second
+	// heartbeatInterval is how often a worker renews the lease of a running
+	// execution. It is well below `leaseDuration`, so that a single missed
+	// heartbeat does not cause the lease to expire.
+	heartbeatInterval = leaseDuration / 3
+	// reaperInterval is how often expired leases are looked for.
+	reaperInterval = 10 * time.Second
+)
+
+// ErrLeaseLost is the cause attached to the context of an execution whose
+// worker lost its lease, e.g. because heartbeats could not be written in
+// time and the execution was requeued by the reaper.
+var ErrLeaseLost = errors.New("the lease on the execution has been lost")
+
+// newWorkerID returns an identifier for this instance's workers that is
+// unique across instances and restarts.
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.NewString())
+}
+
+// heartbeat renews the lease on a running execution every
+// `heartbeatInterval`, until the provided context is done. If the lease
+// cannot be renewed because it is no longer held, the execution's context is
+// cancelled with `ErrLeaseLost`.
+func (s *ExecutionService) heartbeat(ctx context.Context, id uuid.UUID, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			renewed, err := s.repo.RenewLease(id, s.workerID, time.Now().UTC().Add(leaseDuration))
+			if err != nil {
+				// The lease may still be renewed by one of the next heartbeats.
+				log.Errorf("Failed to renew lease for job %s: %v", id, err)
+				continue
+			}
+			if !renewed {
+				log.Warnf("Lost lease for job %s, stopping it", id)
+				cancel(ErrLeaseLost)
+				return
+			}
+		}
+	}
+}
+
+// reapExpiredLeases periodically requeues running executions whose lease has
+// expired, until the provided context is canceled.
+func (s *ExecutionService) reapExpiredLeases(ctx context.Context) {
+	ticker := time.NewTicker(reaperInterval)
+	defer ticker.Stop()
+
+	for {
+		s.requeueExpired()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requeueExpired recovers every running execution whose lease has expired.
+// An execution is only recovered by the instance that managed to release its
+// lease, so that it is never enqueued twice.
+//
+// The lost lease counts as a failed attempt: the execution is retried after
+// its function's backoff if attempts remain, and dead-lettered (or marked as
+// failed if the function is not retried) otherwise.
+func (s *ExecutionService) requeueExpired() {
+	now := time.Now().UTC()
+	expired, err := s.repo.ListExpiredLeases(now)
+	if err != nil {
+		log.Errorf("Failed to list expired leases: %v", err)
+		return
+	}
+
+	for _, exec := range expired {
+		released, err := s.repo.ReleaseExpiredLease(exec.Execution.ID, now)
+		if err != nil {
+			log.Errorf("Failed to release lease for job %s: %v", exec.Execution.ID, err)
+			continue
+		}
+		if !released {
+			continue
+		}
+
+		leaseErr := ErrLeaseLost
+		if exec.Execution.WorkerID != nil {
+			leaseErr = fmt.Errorf("%w: worker %s stopped renewing it", ErrLeaseLost, *exec.Execution.WorkerID)
+		}
+		exec.Execution.Status = models.ExecutionStatusPending
+		exec.Execution.WorkerID = nil
+		exec.Execution.LeaseExpiresAt = nil
+		// Jobs enqueued before attempts were tracked carry no attempt number.
+		exec.Execution.Attempt = max(exec.Execution.Attempt, 1)
+
+		f, ok := s.functions[exec.Trigger.FunctionName]
+		if !ok {
+			// Another instance may know the function, and will run the
+			// next attempt or dead-letter it once it runs out of attempts.
+			log.Warnf("Lease for job %s expired, requeueing it", exec.Execution.ID)
+			s.retry(exec, 0)
+			continue
+		}
+
+		s.recordOutcome(exec.Execution.ID, nil, leaseErr)
+		switch {
+		case exec.Execution.Attempt < f.options.Retry.MaxAttempts:
+			log.Warnf("Lease for job %s expired (attempt %d), retrying it", exec.Execution.ID, exec.Execution.Attempt)
+			s.retry(exec, f.options.Retry.Backoff(exec.Execution.Attempt))
+		case f.options.Retry.MaxAttempts > 1:
+			log.Errorf("Lease for job %s expired and no attempts remain", exec.Execution.ID)
+			job, err := json.Marshal(exec)
+			if err != nil {
+				log.Errorf("Failed to marshal job %s: %v", exec.Execution.ID, err)
+				continue
+			}
+			s.deadLetter(string(job), &exec.Execution.ID, models.DeadLetterReasonRetriesExhausted, leaseErr)
+		default:
+			log.Errorf("Lease for job %s expired, marking it as failed", exec.Execution.ID)
+			if err := s.repo.UpdateStatus(exec.Execution.ID, models.ExecutionStatusFailed); err != nil {
+				log.Errorf("Failed to update status for job %s: %v", exec.Execution.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// cancellationBuffer is the number of cancellation requests buffered for each
+// subscriber of a `MemoryCoordinator`.
+const cancellationBuffer = 64
+
+// MemoryCoordinator is a `Coordinator` held in the memory of the process. It
+// only coordinates the executions of the instances sharing it, i.e. of a
+// single process.
+type MemoryCoordinator struct {
+	mu sync.Mutex
+	// locks holds the held locks by name.
+	locks map[string]memoryClaim
+	// slots holds the expiry of the claimed slots by semaphore name and
+	// holder.
+	slots map[string]map[string]time.Time
+	// starts holds the recorded starts by name and period, oldest first.
+	starts map[rateWindow][]rateStart
+	// subscribers holds the channels returned by `Cancellations`.
+	subscribers []chan uuid.UUID
+}
+
+// memoryClaim is a lock held by a holder until it expires.
+type memoryClaim struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// rateWindow identifies the sliding window of a name and period.
+type rateWindow struct {
+	name   string
+	period time.Duration
+}
+
+// rateStart is a start recorded in a sliding window on behalf of a holder.
+type rateStart struct {
+	holder string
+	at     time.Time
+}
+
+// NewMemoryCoordinator creates a new `MemoryCoordinator` with no locks or
+// slots held.
+func NewMemoryCoordinator() *MemoryCoordinator {
+	return &MemoryCoordinator{
+		locks:  make(map[string]memoryClaim),
+		slots:  make(map[string]map[string]time.Time),
+		starts: make(map[rateWindow][]rateStart),
+	}
+}
+
+// TryLock acquires the named lock if it is free or has expired.
+func (c *MemoryCoordinator) TryLock(_ context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if lock, ok := c.locks[name]; ok && lock.expiresAt.After(now) {
+		return false, nil
+	}
+	c.locks[name] = memoryClaim{holder: holder, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// LockHolder returns the holder of the named lock, unless it has expired.
+func (c *MemoryCoordinator) LockHolder(_ context.Context, name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lock, ok := c.locks[name]; ok && lock.expiresAt.After(time.Now()) {
+		return lock.holder, nil
+	}
+	return "", nil
+}
+
+// RefreshLock extends the named lock if it is still held by the holder.
+func (c *MemoryCoordinator) RefreshLock(_ context.Context, name string, holder string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lock, ok := c.locks[name]; ok && lock.holder == holder {
+		c.locks[name] = memoryClaim{holder: holder, expiresAt: time.Now().Add(ttl)}
+	}
+	return nil
+}
+
+// Unlock releases the named lock if it is still held by the holder.
+func (c *MemoryCoordinator) Unlock(_ context.Context, name string, holder string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lock, ok := c.locks[name]; ok && lock.holder == holder {
+		delete(c.locks, name)
+	}
+	return nil
+}
+
+// AcquireSlot claims a slot of the named semaphore, after freeing the
+// expired ones.
+func (c *MemoryCoordinator) AcquireSlot(
+	_ context.Context,
+	name string,
+	holder string,
+	limit int,
+	ttl time.Duration,
+) (bool, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	slots := c.slots[name]
+	for claimant, expiresAt := range slots {
+		if !expiresAt.After(now) {
+			delete(slots, claimant)
+		}
+	}
+
+	if _, ok := slots[holder]; ok {
+		return false, true, nil
+	}
+	if len(slots) >= limit {
+		return false, false, nil
+	}
+	if slots == nil {
+		slots = make(map[string]time.Time)
+		c.slots[name] = slots
+	}
+	slots[holder] = now.Add(ttl)
+	return true, false, nil
+}
+
+// RefreshSlot extends the slot of the holder, if it still exists.
+func (c *MemoryCoordinator) RefreshSlot(_ context.Context, name string, holder string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.slots[name][holder]; ok {
+		c.slots[name][holder] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// ReleaseSlot frees the slot of the holder.
+func (c *MemoryCoordinator) ReleaseSlot(_ context.Context, name string, holder string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.slots[name], holder)
+	return nil
+}
+
+// Throttle checks and records the start in one sliding window per rate
+// limit. A start already recorded for the holder is replaced.
+func (c *MemoryCoordinator) Throttle(
+	_ context.Context,
+	name string,
+	holder string,
+	limits []models.RateLimit,
+) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+
+	var wait time.Duration
+	for _, limit := range limits {
+		window := rateWindow{name: name, period: limit.Period}
+		starts := slices.DeleteFunc(c.starts[window], func(start rateStart) bool {
+			return !start.at.After(now.Add(-limit.Period))
+		})
+		c.starts[window] = starts
+		if len(starts) >= limit.Limit && len(starts) > 0 {
+			wait = max(wait, starts[0].at.Add(limit.Period).Sub(now))
+		}
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	c.removeStarts(name, holder, limits)
+	for _, limit := range limits {
+		window := rateWindow{name: name, period: limit.Period}
+		c.starts[window] = append(c.starts[window], rateStart{holder: holder, at: now})
+	}
+	return 0, nil
+}
+
+// Unthrottle removes the holder's start from the sliding window of each rate
+// limit.
+func (c *MemoryCoordinator) Unthrottle(
+	_ context.Context,
+	name string,
+	holder string,
+	limits []models.RateLimit,
+) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeStarts(name, holder, limits)
+	return nil
+}
+
+// removeStarts removes the holder's starts from the sliding window of each
+// rate limit. The caller must hold the lock.
+func (c *MemoryCoordinator) removeStarts(name string, holder string, limits []models.RateLimit) {
+	for _, limit := range limits {
+		window := rateWindow{name: name, period: limit.Period}
+		c.starts[window] = slices.DeleteFunc(c.starts[window], func(start rateStart) bool {
+			return start.holder == holder
+		})
+	}
+}
+
+// PublishCancellation delivers the execution ID to every subscriber. A
+// subscriber whose buffer is full misses the request.
+func (c *MemoryCoordinator) PublishCancellation(_ context.Context, id uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, subscriber := range c.subscribers {
+		select {
+		case subscriber <- id:
+		default:
+		}
+	}
+	return nil
+}
+
+// Cancellations subscribes to cancellation requests until the provided
+// context is done.
+func (c *MemoryCoordinator) Cancellations(ctx context.Context) <-chan uuid.UUID {
+	ids := make(chan uuid.UUID, cancellationBuffer)
+	c.mu.Lock()
+	c.subscribers = append(c.subscribers, ids)
+	c.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.subscribers = slices.DeleteFunc(c.subscribers, func(subscriber chan uuid.UUID) bool {
+			return subscriber == ids
+		})
+		close(ids)
+	}()
+	return ids
+}
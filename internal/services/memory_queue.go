@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryQueue is a `Queue` held in the memory of the process. It is meant for
+// single-instance deployments and tests: its jobs are lost when the process
+// exits, and it cannot be shared between instances.
+type MemoryQueue struct {
+	mu sync.Mutex
+	// ready holds the runnable jobs of each queue, sorted by score.
+	ready map[string][]scoredJob
+	// delayed holds the jobs of each queue that become runnable later, with
+	// their due time as score.
+	delayed map[string][]scoredJob
+	// held holds the jobs dequeued from each queue that have not been
+	// acknowledged yet.
+	held map[string][]string
+	// dead holds the dead-letter entries, newest first.
+	dead []string
+	// wake is closed and replaced whenever a job is added, to wake up
+	// blocked calls to `Dequeue`.
+	wake chan struct{}
+}
+
+// scoredJob is a job along with its score in a `MemoryQueue`.
+type scoredJob struct {
+	job   string
+	score float64
+}
+
+// NewMemoryQueue creates a new, empty `MemoryQueue`.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		ready:   make(map[string][]scoredJob),
+		delayed: make(map[string][]scoredJob),
+		held:    make(map[string][]string),
+		wake:    make(chan struct{}),
+	}
+}
+
+// Enqueue adds a job to the named queue.
+func (q *MemoryQueue) Enqueue(_ context.Context, queue string, job string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ready[queue] = insertScored(q.ready[queue], scoredJob{job, queueScore(time.Now(), jobPriority(job))})
+	q.notify()
+	return nil
+}
+
+// Delay adds a job to the delayed jobs of the named queue.
+func (q *MemoryQueue) Delay(_ context.Context, queue string, job string, runAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delayed[queue] = insertScored(q.delayed[queue], scoredJob{job, float64(runAt.UnixMilli())})
+	q.notify()
+	return nil
+}
+
+// Dequeue hands the job that is first in line on the named queue to the
+// caller, moving delayed jobs that are due into the queue beforehand.
+func (q *MemoryQueue) Dequeue(ctx context.Context, queue string) (string, error) {
+	for {
+		q.mu.Lock()
+		q.promote(queue, time.Now())
+		if ready := q.ready[queue]; len(ready) > 0 {
+			job := ready[0].job
+			q.ready[queue] = ready[1:]
+			q.held[queue] = append(q.held[queue], job)
+			q.mu.Unlock()
+			return job, nil
+		}
+
+		// Sleep until a job is added, or the next delayed job is due.
+		wake := q.wake
+		var timer *time.Timer
+		var due <-chan time.Time
+		if delayed := q.delayed[queue]; len(delayed) > 0 {
+			timer = time.NewTimer(time.Until(time.UnixMilli(int64(delayed[0].score))))
+			due = timer.C
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// Ack forgets a dequeued job.
+func (q *MemoryQueue) Ack(_ context.Context, queue string, job string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held[queue] = removeFirst(q.held[queue], job)
+	return nil
+}
+
+// Nack returns a dequeued job to the front of the named queue.
+func (q *MemoryQueue) Nack(_ context.Context, queue string, job string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.held[queue] = removeFirst(q.held[queue], job)
+	q.ready[queue] = insertScored(q.ready[queue], scoredJob{job, math.Inf(-1)})
+	q.notify()
+	return nil
+}
+
+// Remove removes every queued or delayed job of the given execution from the
+// named queue.
+func (q *MemoryQueue) Remove(_ context.Context, queue string, executionID uuid.UUID) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	matches := func(job scoredJob) bool { return jobExecutionID(job.job) == executionID }
+	q.ready[queue] = slices.DeleteFunc(q.ready[queue], matches)
+	q.delayed[queue] = slices.DeleteFunc(q.delayed[queue], matches)
+	return nil
+}
+
+// Restore adds the jobs whose execution has no job on the named queue yet.
+func (q *MemoryQueue) Restore(_ context.Context, queue string, jobs []string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued := make(map[uuid.UUID]bool)
+	for _, job := range q.ready[queue] {
+		queued[jobExecutionID(job.job)] = true
+	}
+	for _, job := range q.delayed[queue] {
+		queued[jobExecutionID(job.job)] = true
+	}
+	for _, job := range q.held[queue] {
+		queued[jobExecutionID(job)] = true
+	}
+
+	now := time.Now()
+	restored := 0
+	for _, job := range jobs {
+		id := jobExecutionID(job)
+		if queued[id] {
+			continue
+		}
+		queued[id] = true
+		if runAt := jobRunAt(job); runAt.After(now) {
+			q.delayed[queue] = insertScored(q.delayed[queue], scoredJob{job, float64(runAt.UnixMilli())})
+		} else {
+			q.ready[queue] = insertScored(q.ready[queue], scoredJob{job, queueScore(now, jobPriority(job))})
+		}
+		restored++
+	}
+	if restored > 0 {
+		q.notify()
+	}
+	return restored, nil
+}
+
+// Start does nothing, as delayed jobs are moved into their queue when
+// dequeuing.
+func (q *MemoryQueue) Start(context.Context, []string) {}
+
+// Release returns every dequeued job that has not been acknowledged to the
+// front of its queue.
+func (q *MemoryQueue) Release(context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for queue, jobs := range q.held {
+		for _, job := range jobs {
+			q.ready[queue] = insertScored(q.ready[queue], scoredJob{job, math.Inf(-1)})
+		}
+		delete(q.held, queue)
+	}
+	q.notify()
+	return nil
+}
+
+// Bury adds an entry to the dead-letter queue.
+func (q *MemoryQueue) Bury(_ context.Context, entry string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead = slices.Insert(q.dead, 0, entry)
+	return nil
+}
+
+// DeadLetters returns a copy of the dead-letter queue.
+func (q *MemoryQueue) DeadLetters(context.Context) ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.dead), nil
+}
+
+// RemoveDeadLetter removes an entry from the dead-letter queue.
+func (q *MemoryQueue) RemoveDeadLetter(_ context.Context, entry string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	count := len(q.dead)
+	q.dead = removeFirst(q.dead, entry)
+	return len(q.dead) < count, nil
+}
+
+// PurgeDeadLetters empties the dead-letter queue.
+func (q *MemoryQueue) PurgeDeadLetters(context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead = nil
+	return nil
+}
+
+// promote moves the delayed jobs of the named queue that are due at `now`
+// into the queue. The caller must hold the lock.
+func (q *MemoryQueue) promote(queue string, now time.Time) {
+	delayed := q.delayed[queue]
+	due := 0
+	for due < len(delayed) && delayed[due].score <= float64(now.UnixMilli()) {
+		job := delayed[due].job
+		q.ready[queue] = insertScored(q.ready[queue], scoredJob{job, queueScore(now, jobPriority(job))})
+		due++
+	}
+	q.delayed[queue] = delayed[due:]
+}
+
+// notify wakes up every blocked call to `Dequeue`. The caller must hold the
+// lock.
+func (q *MemoryQueue) notify() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// insertScored inserts a job into a slice sorted by score, after the jobs
+// with the same score.
+func insertScored(jobs []scoredJob, job scoredJob) []scoredJob {
+	i, _ := slices.BinarySearchFunc(jobs, job.score, func(j scoredJob, score float64) int {
+		if j.score <= score {
+			return -1
+		}
+		return 1
+	})
+	return slices.Insert(jobs, i, job)
+}
+
+// removeFirst removes the first occurrence of a value from a slice.
+func removeFirst(values []string, value string) []string {
+	if i := slices.Index(values, value); i >= 0 {
+		return slices.Delete(values, i, i+1)
+	}
+	return values
+}
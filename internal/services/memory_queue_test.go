@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// testQueue is the queue the tests enqueue jobs on.
+const testQueue = "test"
+
+// newTestJob returns the job of a new pending execution with the given
+// priority, scheduled at `scheduledAt` unless it is zero.
+func newTestJob(t *testing.T, priority int, scheduledAt time.Time) string {
+	t.Helper()
+	triggerID := uuid.New()
+	payload := models.ExecutionWithTrigger{
+		Execution: models.Execution{
+			ID:        uuid.New(),
+			Status:    models.ExecutionStatusPending,
+			TriggerID: triggerID,
+			Attempt:   1,
+			Priority:  priority,
+		},
+		Trigger: models.Trigger{
+			ID:           &triggerID,
+			TriggerType:  models.TriggerTypeEvent,
+			FunctionName: "fn",
+			Priority:     priority,
+		},
+	}
+	if !scheduledAt.IsZero() {
+		payload.Execution.ScheduledAt = &scheduledAt
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// dequeueWithin dequeues a job from the test queue, failing the test unless
+// one is available within the given timeout.
+func dequeueWithin(t *testing.T, queue Queue, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	job, err := queue.Dequeue(ctx, testQueue)
+	if err != nil {
+		t.Fatalf("no job dequeued within %s: %v", timeout, err)
+	}
+	return job
+}
+
+// expectEmpty fails the test if a job can be dequeued from the test queue
+// within the given timeout.
+func expectEmpty(t *testing.T, queue Queue, timeout time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	job, err := queue.Dequeue(ctx, testQueue)
+	if err == nil {
+		t.Fatalf("dequeued job %s from a queue expected to be empty", job)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// expectJobs dequeues as many jobs as given from the test queue, failing the
+// test unless they come in the given order.
+func expectJobs(t *testing.T, queue Queue, want ...string) {
+	t.Helper()
+	for i, job := range want {
+		if got := dequeueWithin(t, queue, time.Second); got != job {
+			t.Fatalf("job %d: got %s, want %s", i, got, job)
+		}
+	}
+}
+
+func TestQueueScoreAging(t *testing.T) {
+	now := time.Now()
+	if queueScore(now, 1) >= queueScore(now, 0) {
+		t.Error("a higher priority does not move a job ahead")
+	}
+	if queueScore(now.Add(-2*priorityAging), 0) >= queueScore(now, 1) {
+		t.Error("a job waiting for two aging periods is not ahead of a job one priority level higher")
+	}
+	if queueScore(now, 2) >= queueScore(now.Add(-priorityAging/2), 1) {
+		t.Error("a job one priority level higher is not ahead of a job waiting for less than an aging period")
+	}
+}
+
+func TestMemoryQueueOrder(t *testing.T) {
+	queue := NewMemoryQueue()
+	ctx := context.Background()
+	low, first, second, high := newTestJob(t, -1, time.Time{}), newTestJob(t, 0, time.Time{}),
+		newTestJob(t, 0, time.Time{}), newTestJob(t, 5, time.Time{})
+	for _, job := range []string{low, first, second, high} {
+		if err := queue.Enqueue(ctx, testQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectJobs(t, queue, high, first, second, low)
+	expectEmpty(t, queue, 10*time.Millisecond)
+}
+
+func TestMemoryQueueDelay(t *testing.T) {
+	queue := NewMemoryQueue()
+	ctx := context.Background()
+	due, delayed := newTestJob(t, 0, time.Time{}), newTestJob(t, 10, time.Time{})
+	if err := queue.Delay(ctx, testQueue, delayed, time.Now().Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Delay(ctx, testQueue, due, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectJobs(t, queue, due)
+	expectEmpty(t, queue, 20*time.Millisecond)
+	// A blocked call wakes up once the delayed job is due.
+	expectJobs(t, queue, delayed)
+}
+
+func TestMemoryQueueNackAndRelease(t *testing.T) {
+	queue := NewMemoryQueue()
+	ctx := context.Background()
+	first, second := newTestJob(t, 0, time.Time{}), newTestJob(t, 0, time.Time{})
+	for _, job := range []string{first, second} {
+		if err := queue.Enqueue(ctx, testQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectJobs(t, queue, first)
+	if err := queue.Nack(ctx, testQueue, first); err != nil {
+		t.Fatal(err)
+	}
+	expectJobs(t, queue, first)
+
+	if err := queue.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectJobs(t, queue, first, second)
+
+	// Acknowledged jobs are not released.
+	for _, job := range []string{first, second} {
+		if err := queue.Ack(ctx, testQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectEmpty(t, queue, 10*time.Millisecond)
+}
+
+func TestMemoryQueueRemove(t *testing.T) {
+	queue := NewMemoryQueue()
+	ctx := context.Background()
+	removed, kept := newTestJob(t, 0, time.Time{}), newTestJob(t, 0, time.Time{})
+	if err := queue.Enqueue(ctx, testQueue, removed); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Delay(ctx, testQueue, removed, time.Now().Add(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue(ctx, testQueue, kept); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := queue.Remove(ctx, testQueue, jobExecutionID(removed)); err != nil {
+		t.Fatal(err)
+	}
+	expectJobs(t, queue, kept)
+	expectEmpty(t, queue, 50*time.Millisecond)
+}
+
+func TestMemoryQueueDequeueWakesUp(t *testing.T) {
+	queue := NewMemoryQueue()
+	job := newTestJob(t, 0, time.Time{})
+
+	dequeued := make(chan string, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		job, err := queue.Dequeue(ctx, testQueue)
+		if err != nil {
+			job = err.Error()
+		}
+		dequeued <- job
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := queue.Enqueue(context.Background(), testQueue, job); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-dequeued:
+		if got != job {
+			t.Fatalf("got %s, want %s", got, job)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Dequeue did not wake up")
+	}
+}
+
+func TestMemoryQueueRestore(t *testing.T) {
+	queue := NewMemoryQueue()
+	ctx := context.Background()
+	queued, held, missing := newTestJob(t, 0, time.Time{}), newTestJob(t, 0, time.Time{}), newTestJob(t, 0, time.Time{})
+	scheduled := newTestJob(t, 0, time.Now().Add(100*time.Millisecond))
+	if err := queue.Enqueue(ctx, testQueue, held); err != nil {
+		t.Fatal(err)
+	}
+	expectJobs(t, queue, held)
+	if err := queue.Enqueue(ctx, testQueue, queued); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := queue.Restore(ctx, testQueue, []string{queued, held, missing, scheduled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 2 {
+		t.Fatalf("restored %d jobs, want 2", restored)
+	}
+	restored, err = queue.Restore(ctx, testQueue, []string{missing, scheduled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 0 {
+		t.Fatalf("restored %d jobs again, want none", restored)
+	}
+
+	expectJobs(t, queue, queued, missing)
+	expectEmpty(t, queue, 20*time.Millisecond)
+	expectJobs(t, queue, scheduled)
+}
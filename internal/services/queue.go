@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+)
+
+// priorityAging is how much waiting time one priority level is worth. A job
+// is dequeued ahead of a job with a priority one level higher as long as it
+// has been enqueued more than `priorityAging` earlier, so that jobs with a low
+// priority are not starved by a steady flow of urgent ones.
+const priorityAging = time.Minute
+
+// Queue carries jobs from the instances enqueueing them to the workers running
+// them. Jobs are JSON-encoded `models.ExecutionWithTrigger` values, which
+// implementations only decode as far as needed to order them.
+//
+// A dequeued job is held by the instance that dequeued it until it is
+// acknowledged with `Ack` or returned with `Nack`. Implementations shared
+// between instances recover the jobs held by instances that died.
+type Queue interface {
+	// Enqueue adds a job to the named queue. Jobs with a higher priority are
+	// dequeued first, as long as older jobs have not waited for too long.
+	Enqueue(ctx context.Context, queue string, job string) error
+	// Delay adds a job to the named queue once `runAt` has passed.
+	Delay(ctx context.Context, queue string, job string, runAt time.Time) error
+	// Dequeue blocks until a job is available on the named queue and hands it
+	// to this instance. It returns the context's error once it is done.
+	Dequeue(ctx context.Context, queue string) (string, error)
+	// Ack removes a dequeued job for good. It must only be called once the
+	// outcome of the job has been recorded.
+	Ack(ctx context.Context, queue string, job string) error
+	// Nack returns a dequeued job to the front of the named queue.
+	Nack(ctx context.Context, queue string, job string) error
+	// Remove removes every queued or delayed job of the given execution from
+	// the named queue.
+	Remove(ctx context.Context, queue string, executionID uuid.UUID) error
+	// Restore adds the given jobs to the named queue, delaying those whose
+	// execution is scheduled later until then, and skipping those whose
+	// execution already has a job queued, delayed or held on the queue. It
+	// returns the number of jobs added. Unlike `Enqueue`, it can be called
+	// repeatedly, and by several instances, with the same jobs.
+	Restore(ctx context.Context, queue string, jobs []string) (int, error)
+
+	// Start launches the background work needed to serve the named queues,
+	// such as moving delayed jobs into their queue once due, until the
+	// provided context is canceled.
+	Start(ctx context.Context, queues []string)
+	// Release returns every job still held by this instance to its queue. It
+	// is called once the instance has stopped running jobs.
+	Release(ctx context.Context) error
+
+	// Bury adds an entry to the dead-letter queue.
+	Bury(ctx context.Context, entry string) error
+	// DeadLetters returns every entry of the dead-letter queue, newest first.
+	DeadLetters(ctx context.Context) ([]string, error)
+	// RemoveDeadLetter removes an entry from the dead-letter queue. It
+	// reports whether the entry was there.
+	RemoveDeadLetter(ctx context.Context, entry string) (bool, error)
+	// PurgeDeadLetters removes every entry from the dead-letter queue.
+	PurgeDeadLetters(ctx context.Context) error
+}
+
+// queueScore returns the score of a job with the given priority that is
+// enqueued at `enqueuedAt`. Jobs with the lowest score are dequeued first, and
+// every priority level moves the job `priorityAging` ahead.
+func queueScore(enqueuedAt time.Time, priority int) float64 {
+	return float64(enqueuedAt.UnixMilli() - int64(priority)*priorityAging.Milliseconds())
+}
+
+// jobExecutionID returns the ID of the execution a queued job belongs to, or
+// `uuid.Nil` if the job cannot be decoded.
+func jobExecutionID(job string) uuid.UUID {
+	var payload models.ExecutionWithTrigger
+	if err := json.Unmarshal([]byte(job), &payload); err != nil {
+		return uuid.Nil
+	}
+	return payload.Execution.ID
+}
+
+// jobPriority returns the priority of a queued job, or 0 if the job cannot be
+// decoded.
+func jobPriority(job string) int {
+	var payload models.ExecutionWithTrigger
+	if err := json.Unmarshal([]byte(job), &payload); err != nil {
+		return 0
+	}
+	return payload.Execution.Priority
+}
+
+// jobRunAt returns the time the execution of a queued job is scheduled at, or
+// the zero time if it is not scheduled or the job cannot be decoded.
+func jobRunAt(job string) time.Time {
+	var payload models.ExecutionWithTrigger
+	if err := json.Unmarshal([]byte(job), &payload); err != nil || payload.Execution.ScheduledAt == nil {
+		return time.Time{}
+	}
+	return *payload.Execution.ScheduledAt
+}
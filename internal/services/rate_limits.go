@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/labstack/gommon/log"
+)
+
+// validRateLimits returns the rate limits a function is registered with,
+// rejecting the limits whose limit or period is not positive, as executions
+// would never start, and keeping the lowest of the limits sharing a period.
+func validRateLimits(name string, limits []models.RateLimit) []models.RateLimit {
+	var valid []models.RateLimit
+	for _, limit := range limits {
+		if limit.Limit <= 0 || limit.Period <= 0 {
+			log.Errorf("Ignoring invalid rate limit of %d per %s for function %s, both must be positive", limit.Limit, limit.Period, name)
+			continue
+		}
+		i := slices.IndexFunc(valid, func(existing models.RateLimit) bool { return existing.Period == limit.Period })
+		if i < 0 {
+			valid = append(valid, limit)
+			continue
+		}
+		valid[i].Limit = min(valid[i].Limit, limit.Limit)
+	}
+	return valid
+}
+
+// checkRateLimits enforces the rate limits of a function before one of its
+// jobs starts, recording the start. It reports whether the job may start; a
+// job over a limit stays pending and is delayed until it fits within the
+// limit. If the job does not start after all, `forgetStart` must be called.
+func (s *ExecutionService) checkRateLimits(payload *models.ExecutionWithTrigger, limits []models.RateLimit) bool {
+	if len(limits) == 0 {
+		return true
+	}
+
+	id := payload.Execution.ID
+	wait, err := s.coordinator.Throttle(context.Background(), payload.Trigger.FunctionName, rateLimitHolder(payload), limits)
+	if err != nil {
+		log.Errorf("Failed to check rate limits for job %s, delaying it: %v", id, err)
+		s.waitForLock(payload)
+		return false
+	}
+	if wait == 0 {
+		return true
+	}
+	if !s.stillPending(id) {
+		// The job is skipped once it fails to start.
+		return true
+	}
+
+	log.Infof("Delaying job %s by %s as %s is rate limited", id, wait, payload.Trigger.FunctionName)
+	s.delayOrFail(payload, time.Now().Add(wait))
+	return false
+}
+
+// forgetStart removes the start recorded by `checkRateLimits` for a job that
+// did not start after all, e.g. because its execution was no longer pending,
+// so that it does not count against the rate limits.
+func (s *ExecutionService) forgetStart(payload *models.ExecutionWithTrigger, limits []models.RateLimit) {
+	if len(limits) == 0 {
+		return
+	}
+	err := s.coordinator.Unthrottle(context.Background(), payload.Trigger.FunctionName, rateLimitHolder(payload), limits)
+	if err != nil {
+		log.Errorf("Failed to forget start of job %s: %v", payload.Execution.ID, err)
+	}
+}
+
+// rateLimitHolder identifies the start of the attempt of a job in the rate
+// limits. Duplicate jobs of the same attempt share it, as at most one of them
+// starts.
+func rateLimitHolder(payload *models.ExecutionWithTrigger) string {
+	return fmt.Sprintf("%s:%d", payload.Execution.ID, payload.Execution.Attempt)
+}
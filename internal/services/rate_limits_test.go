@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+)
+
+func TestValidRateLimits(t *testing.T) {
+	var options models.FunctionOptions
+	for _, opt := range []models.FunctionOption{
+		models.WithRateLimit(10, time.Second),
+		models.WithRateLimit(0, time.Minute),
+		models.WithRateLimit(1000, time.Hour),
+		models.WithRateLimit(-1, time.Second),
+		models.WithRateLimit(5, time.Second),
+		models.WithRateLimit(10, 0),
+		models.WithRateLimit(20, time.Second),
+		models.WithRateLimit(10, -time.Second),
+	} {
+		opt(&options)
+	}
+
+	got := validRateLimits("fn", options.RateLimits)
+	want := []models.RateLimit{{Limit: 5, Period: time.Second}, {Limit: 1000, Period: time.Hour}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got rate limits %v, want %v", got, want)
+	}
+}
+
+func TestExecutionServiceRateLimit(t *testing.T) {
+	e := newExecutionTest()
+	var runs atomic.Int32
+	e.register(func(context.Context, *models.Trigger) (any, error) {
+		runs.Add(1)
+		return nil, nil
+	}, models.WithRateLimit(1, time.Minute))
+	first, second := e.newJob(t, 1), e.newJob(t, 1)
+
+	e.execute(t, first, time.Second)
+	// The second execution is deferred until the first start leaves the
+	// window.
+	e.execute(t, second, time.Second)
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("function ran %d times, want 1", got)
+	}
+	if got := e.execution(t, second).Status; got != models.ExecutionStatusPending {
+		t.Fatalf("second execution: got status %s, want %s", got, models.ExecutionStatusPending)
+	}
+	_, runAt := e.delayedJob(t, second)
+	if wait := time.Until(runAt); wait < 50*time.Second || wait > time.Minute {
+		t.Fatalf("second execution is delayed by %s, want about a minute", wait)
+	}
+}
+
+func TestExecutionServiceRateLimitSkippedJob(t *testing.T) {
+	e := newExecutionTest()
+	var runs atomic.Int32
+	e.register(func(context.Context, *models.Trigger) (any, error) {
+		runs.Add(1)
+		return nil, nil
+	}, models.WithRateLimit(1, time.Minute))
+	cancelled, next := e.newJob(t, 1), e.newJob(t, 1)
+	if _, err := e.service.CancelExecution(jobExecutionID(cancelled)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A job that does not start does not count against the limit.
+	e.execute(t, cancelled, time.Second)
+	e.execute(t, next, time.Second)
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("function ran %d times, want 1", got)
+	}
+	if got := e.execution(t, next).Status; got != models.ExecutionStatusCompleted {
+		t.Fatalf("got status %s, want %s", got, models.ExecutionStatusCompleted)
+	}
+	if delayed := e.delayed(); len(delayed) != 0 {
+		t.Fatalf("got %d delayed jobs, want none", len(delayed))
+	}
+}
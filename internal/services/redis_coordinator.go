@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// lockKeyPrefix prefixes the Redis keys holding locks. The value of a
+	// lock is its holder.
+	lockKeyPrefix = "quego:lock:"
+	// slotsKeyPrefix prefixes the Redis sorted sets holding the slots of a
+	// semaphore, scored by the Unix time (in milliseconds) they expire at.
+	slotsKeyPrefix = "quego:slots:"
+	// rateLimitKeyPrefix prefixes the Redis sorted sets holding the start
+	// times recorded under a name, one per rate limit period.
+	rateLimitKeyPrefix = "quego:ratelimit:"
+	// cancelChannel is the Redis Pub/Sub channel on which the IDs of running
+	// executions to cancel are published, so that the instance running them
+	// can cancel their context.
+	cancelChannel = "quego:cancel"
+)
+
+// releaseLockScript deletes the lock KEYS[1] if it is still held by ARGV[1].
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// refreshLockScript extends the lock KEYS[1] by ARGV[2] milliseconds if it is
+// still held by ARGV[1].
+var refreshLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// acquireSlotScript claims a slot of the sorted set KEYS[1] for ARGV[1] until
+// ARGV[3], if fewer than ARGV[4] unexpired slots are held at ARGV[2]. Slots
+// are scored by the Unix time (in milliseconds) they expire at, so that slots
+// of crashed instances are eventually freed. It returns 1 if the slot has
+// been claimed, 0 if ARGV[1] already holds one, and nil if no slot is
+// available.
+var acquireSlotScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	return 0
+end
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[4]) then
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+	redis.call('PEXPIREAT', KEYS[1], ARGV[3])
+	return 1
+end
+return false
+`)
+
+// rateLimitScript implements a sliding-window rate limiter over the sorted
+// sets KEYS, each scored by the Unix time (in milliseconds) of the starts it
+// records. ARGV[1] is the current time, ARGV[2] the holder of this start,
+// followed by a limit and a period (in milliseconds) for each key.
+//
+// If every limit allows one more start, it is recorded in all windows and 0
+// is returned. Otherwise, nothing is recorded and the number of milliseconds
+// until the start would be allowed is returned.
+var rateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local wait = 0
+for i, key in ipairs(KEYS) do
+	local limit = tonumber(ARGV[1 + i * 2])
+	local period = tonumber(ARGV[2 + i * 2])
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now - period)
+	if redis.call('ZCARD', key) >= limit then
+		local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+		wait = math.max(wait, tonumber(oldest[2]) + period - now)
+	end
+end
+if wait > 0 then
+	return wait
+end
+for i, key in ipairs(KEYS) do
+	redis.call('ZADD', key, now, ARGV[2])
+	redis.call('PEXPIRE', key, ARGV[2 + i * 2])
+end
+return 0
+`)
+
+// RedisCoordinator is a `Coordinator` backed by Redis, which enforces limits
+// and delivers cancellations across all instances sharing the Redis server.
+type RedisCoordinator struct {
+	client *redis.Client
+}
+
+// NewRedisCoordinator creates a new `RedisCoordinator` backed by the provided
+// Redis client.
+func NewRedisCoordinator(client *redis.Client) *RedisCoordinator {
+	return &RedisCoordinator{client: client}
+}
+
+// TryLock sets the lock's key, unless it exists already.
+func (c *RedisCoordinator) TryLock(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	acquired, err := c.client.SetNX(ctx, lockKeyPrefix+name, holder, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	return acquired, nil
+}
+
+// LockHolder returns the value of the lock's key.
+func (c *RedisCoordinator) LockHolder(ctx context.Context, name string) (string, error) {
+	holder, err := c.client.Get(ctx, lockKeyPrefix+name).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up lock holder: %w", err)
+	}
+	return holder, nil
+}
+
+// RefreshLock extends the expiry of the lock's key.
+func (c *RedisCoordinator) RefreshLock(ctx context.Context, name string, holder string, ttl time.Duration) error {
+	err := refreshLockScript.Run(ctx, c.client, []string{lockKeyPrefix + name}, holder, ttl.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to refresh lock: %w", err)
+	}
+	return nil
+}
+
+// Unlock deletes the lock's key.
+func (c *RedisCoordinator) Unlock(ctx context.Context, name string, holder string) error {
+	if err := releaseLockScript.Run(ctx, c.client, []string{lockKeyPrefix + name}, holder).Err(); err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+// AcquireSlot claims a slot in the semaphore's sorted set.
+func (c *RedisCoordinator) AcquireSlot(
+	ctx context.Context,
+	name string,
+	holder string,
+	limit int,
+	ttl time.Duration,
+) (bool, bool, error) {
+	now := time.Now()
+	result, err := acquireSlotScript.Run(
+		ctx,
+		c.client,
+		[]string{slotsKeyPrefix + name},
+		holder,
+		now.UnixMilli(),
+		now.Add(ttl).UnixMilli(),
+		limit,
+	).Int()
+	switch {
+	case err == redis.Nil:
+		return false, false, nil
+	case err != nil:
+		return false, false, fmt.Errorf("failed to acquire slot: %w", err)
+	}
+	return result == 1, result == 0, nil
+}
+
+// RefreshSlot updates the expiry of the holder's slot, if it still exists.
+func (c *RedisCoordinator) RefreshSlot(ctx context.Context, name string, holder string, ttl time.Duration) error {
+	member := redis.Z{Score: float64(time.Now().Add(ttl).UnixMilli()), Member: holder}
+	if err := c.client.ZAddXX(ctx, slotsKeyPrefix+name, member).Err(); err != nil {
+		return fmt.Errorf("failed to refresh slot: %w", err)
+	}
+	return nil
+}
+
+// ReleaseSlot removes the holder's slot from the semaphore's sorted set.
+func (c *RedisCoordinator) ReleaseSlot(ctx context.Context, name string, holder string) error {
+	if err := c.client.ZRem(ctx, slotsKeyPrefix+name, holder).Err(); err != nil {
+		return fmt.Errorf("failed to release slot: %w", err)
+	}
+	return nil
+}
+
+// Throttle checks and records the start in one sorted set per rate limit.
+func (c *RedisCoordinator) Throttle(
+	ctx context.Context,
+	name string,
+	holder string,
+	limits []models.RateLimit,
+) (time.Duration, error) {
+	if len(limits) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, 0, len(limits))
+	args := []any{time.Now().UnixMilli(), holder}
+	for _, limit := range limits {
+		keys = append(keys, rateLimitKey(name, limit))
+		args = append(args, limit.Limit, limit.Period.Milliseconds())
+	}
+
+	wait, err := rateLimitScript.Run(ctx, c.client, keys, args...).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rate limits: %w", err)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Unthrottle removes the holder's start from the sorted set of each rate
+// limit.
+func (c *RedisCoordinator) Unthrottle(
+	ctx context.Context,
+	name string,
+	holder string,
+	limits []models.RateLimit,
+) error {
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, limit := range limits {
+			pipe.ZRem(ctx, rateLimitKey(name, limit), holder)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove start from rate limits: %w", err)
+	}
+	return nil
+}
+
+// rateLimitKey returns the key of the sorted set recording the starts under
+// the given name for the period of the given limit.
+func rateLimitKey(name string, limit models.RateLimit) string {
+	return fmt.Sprintf("%s%s:%d", rateLimitKeyPrefix, name, limit.Period.Milliseconds())
+}
+
+// PublishCancellation publishes the execution ID on the cancellation
+// channel.
+func (c *RedisCoordinator) PublishCancellation(ctx context.Context, id uuid.UUID) error {
+	if err := c.client.Publish(ctx, cancelChannel, id.String()).Err(); err != nil {
+		return fmt.Errorf("failed to publish cancellation: %w", err)
+	}
+	return nil
+}
+
+// Cancellations subscribes to the cancellation channel.
+func (c *RedisCoordinator) Cancellations(ctx context.Context) <-chan uuid.UUID {
+	ids := make(chan uuid.UUID)
+	go func() {
+		defer close(ids)
+		pubsub := c.client.Subscribe(ctx, cancelChannel)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				id, err := uuid.Parse(message.Payload)
+				if err != nil {
+					log.Errorf("Received malformed cancellation request %q: %v", message.Payload, err)
+					continue
+				}
+				select {
+				case ids <- id:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ids
+}
@@ -0,0 +1,730 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// queueKeyPrefix prefixes the Redis sorted sets holding the jobs waiting
+	// to be executed on each queue, scored by `queueScore`.
+	queueKeyPrefix = "quego:jobs:"
+	// delayedKeyPrefix prefixes the Redis sorted sets holding the jobs of
+	// each queue that become runnable later, scored by the Unix time (in
+	// milliseconds) they are due at.
+	delayedKeyPrefix = "quego:delayed:"
+	// processingKeyPrefix prefixes the Redis lists holding the jobs each
+	// instance has dequeued from each queue but not yet acknowledged.
+	processingKeyPrefix = "quego:processing:"
+	// workersKey is the Redis set of the processing lists that may exist, as
+	// returned by `workerQueue`.
+	workersKey = "quego:workers"
+	// workerAliveKeyPrefix prefixes the Redis keys that exist for as long as
+	// the corresponding instance is alive.
+	workerAliveKeyPrefix = "quego:worker:"
+	// indexKeyPrefix prefixes the Redis hashes mapping the ID of each
+	// execution with a job in a queue or its delayed set to that job.
+	indexKeyPrefix = "quego:index:"
+	// indexedKeyPrefix prefixes the Redis keys that exist once the jobs added
+	// to each queue before it had an index have been indexed.
+	indexedKeyPrefix = "quego:indexed:"
+	// wakeKeyPrefix prefixes the Redis lists that blocked calls to `Dequeue`
+	// wait on, onto which a wake-up is pushed for each job made runnable on
+	// each queue.
+	wakeKeyPrefix = "quego:wake:"
+	// deadLetterKey is the Redis list holding dead-lettered jobs, newest
+	// first.
+	deadLetterKey = "quego:dead"
+	// legacyQueueKey is the Redis list that held the jobs waiting to be
+	// executed before jobs were prioritized.
+	legacyQueueKey = "quego:queue"
+	// legacyJobsKey and legacyDelayedKey are the Redis sorted sets that held
+	// the waiting and delayed jobs before named queues were introduced.
+	legacyJobsKey    = "quego:jobs"
+	legacyDelayedKey = "quego:delayed"
+
+	// delayedPollInterval is how often due jobs are moved from the delayed
+	// sets into their queue.
+	delayedPollInterval = 500 * time.Millisecond
+	// delayedBatchSize is the maximum number of due jobs moved at once.
+	delayedBatchSize = 100
+	// dequeueWaitTimeout is how long `Dequeue` waits for a wake-up before it
+	// checks its queue again, in case a wake-up was missed.
+	dequeueWaitTimeout = time.Second
+	// wakeListSize is the maximum number of wake-ups kept for each queue.
+	wakeListSize = 100
+	// recoveryInterval is how often the jobs held by dead instances are
+	// looked for.
+	recoveryInterval = 10 * time.Second
+	// restoreBatchSize is the maximum number of jobs restored at once.
+	restoreBatchSize = 100
+)
+
+// jobIndexLua defines the Lua functions shared by the scripts maintaining the
+// index of a queue. `job_id` returns the ID of the execution a job belongs to,
+// or nil if the job cannot be decoded. `add_job` adds a job to the sorted set
+// `key`, which is either the queue `queue` or its delayed set `delayed`, and
+// records it in the index `index`, replacing the job of the same execution
+// found there beforehand.
+const jobIndexLua = `
+local function job_id(job)
+	local ok, decoded = pcall(cjson.decode, job)
+	if ok and type(decoded) == 'table' and type(decoded.id) == 'string' then
+		return decoded.id
+	end
+	return nil
+end
+
+local function add_job(index, queue, delayed, key, score, job)
+	local id = job_id(job)
+	if id then
+		local replaced = redis.call('HGET', index, id)
+		if replaced then
+			redis.call('ZREM', queue, replaced)
+			redis.call('ZREM', delayed, replaced)
+		end
+		redis.call('HSET', index, id, job)
+	end
+	redis.call('ZADD', key, score, job)
+end
+`
+
+// addJobScript atomically adds the job ARGV[3] with the score ARGV[2] to the
+// queue KEYS[1] or its delayed set KEYS[2], as selected by the index ARGV[1]
+// of the key, and records it in the index KEYS[3].
+var addJobScript = redis.NewScript(jobIndexLua + `
+add_job(KEYS[3], KEYS[1], KEYS[2], KEYS[tonumber(ARGV[1])], ARGV[2], ARGV[3])
+return 1
+`)
+
+// promoteDelayedScript atomically moves up to ARGV[2] jobs whose score is at
+// most ARGV[1] from the delayed set KEYS[1] into the queue KEYS[2]. Each job
+// is scored like `queueScore` does, with ARGV[3] being `priorityAging` in
+// milliseconds; jobs that cannot be decoded get the default priority.
+var promoteDelayedScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local aging = tonumber(ARGV[3])
+local jobs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, ARGV[2])
+for _, job in ipairs(jobs) do
+	local priority = 0
+	local ok, decoded = pcall(cjson.decode, job)
+	if ok and type(decoded) == 'table' and type(decoded.priority) == 'number' then
+		priority = decoded.priority
+	end
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('ZADD', KEYS[2], now - priority * aging, job)
+end
+return #jobs
+`)
+
+// recoverProcessingScript atomically moves every job from the processing list
+// KEYS[1] of a dead instance back to the front of the queue KEYS[2], whose
+// delayed set and index are KEYS[4] and KEYS[5], and removes the processing
+// list ARGV[1] from the set of workers KEYS[3]. Jobs whose execution got
+// another job in the meantime, e.g. a retry, are dropped.
+var recoverProcessingScript = redis.NewScript(jobIndexLua + `
+local moved = 0
+local job = redis.call('RPOP', KEYS[1])
+while job do
+	local id = job_id(job)
+	if not id or redis.call('HEXISTS', KEYS[5], id) == 0 then
+		add_job(KEYS[5], KEYS[2], KEYS[4], KEYS[2], '-inf', job)
+		moved = moved + 1
+	end
+	job = redis.call('RPOP', KEYS[1])
+end
+redis.call('SREM', KEYS[3], ARGV[1])
+return moved
+`)
+
+// dequeueScript atomically moves the job with the lowest score from the queue
+// KEYS[1] into the processing list KEYS[2], removing it from the index
+// KEYS[3]. It returns nil if the queue is empty.
+var dequeueScript = redis.NewScript(jobIndexLua + `
+local jobs = redis.call('ZRANGE', KEYS[1], 0, 0)
+if #jobs == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], jobs[1])
+redis.call('LPUSH', KEYS[2], jobs[1])
+local id = job_id(jobs[1])
+if id and redis.call('HGET', KEYS[3], id) == jobs[1] then
+	redis.call('HDEL', KEYS[3], id)
+end
+return jobs[1]
+`)
+
+// nackScript atomically moves the job ARGV[1] from the processing list
+// KEYS[1] back to the front of the queue KEYS[2], whose delayed set and index
+// are KEYS[3] and KEYS[4].
+var nackScript = redis.NewScript(jobIndexLua + `
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+add_job(KEYS[4], KEYS[2], KEYS[3], KEYS[2], '-inf', ARGV[1])
+return 1
+`)
+
+// removeScript atomically removes the job of the execution ARGV[1] from the
+// queue KEYS[1] or its delayed set KEYS[2], along with its entry in the index
+// KEYS[3].
+var removeScript = redis.NewScript(`
+local job = redis.call('HGET', KEYS[3], ARGV[1])
+if job then
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('ZREM', KEYS[2], job)
+	redis.call('HDEL', KEYS[3], ARGV[1])
+end
+return 1
+`)
+
+// restoreScript atomically adds jobs to the queue KEYS[1] or its delayed set
+// KEYS[2], unless a job of the same execution is in the index KEYS[3] or in
+// one of the processing lists KEYS[4...]. ARGV holds four values per job: the
+// ID of its execution, the index of the key to add it to, its score and the
+// job itself. It returns the number of jobs added.
+//
+// The processing lists are decoded in full, as they hold no more jobs than
+// their instance has workers.
+var restoreScript = redis.NewScript(jobIndexLua + `
+local held = {}
+for i = 4, #KEYS do
+	for _, job in ipairs(redis.call('LRANGE', KEYS[i], 0, -1)) do
+		local id = job_id(job)
+		if id then
+			held[id] = true
+		end
+	end
+end
+
+local restored = 0
+for i = 1, #ARGV, 4 do
+	if not held[ARGV[i]] and redis.call('HEXISTS', KEYS[3], ARGV[i]) == 0 then
+		add_job(KEYS[3], KEYS[1], KEYS[2], KEYS[tonumber(ARGV[i + 1])], ARGV[i + 2], ARGV[i + 3])
+		restored = restored + 1
+	end
+end
+return restored
+`)
+
+// indexScript atomically records every job of the queue KEYS[1] and its
+// delayed set KEYS[2] in the index KEYS[3], unless the key KEYS[4] marks the
+// queue as indexed already, in which case it does nothing. Jobs added before
+// the index existed are thereby indexed once. It returns the number of jobs
+// indexed.
+var indexScript = redis.NewScript(jobIndexLua + `
+if redis.call('EXISTS', KEYS[4]) == 1 then
+	return 0
+end
+local indexed = 0
+for i = 1, 2 do
+	for _, job in ipairs(redis.call('ZRANGE', KEYS[i], 0, -1)) do
+		local id = job_id(job)
+		if id and redis.call('HSETNX', KEYS[3], id, job) == 1 then
+			indexed = indexed + 1
+		end
+	end
+end
+redis.call('SET', KEYS[4], 1)
+return indexed
+`)
+
+// migrateLegacyScript atomically moves the jobs left by older versions into
+// the queue KEYS[4] and its delayed set KEYS[5], recording them in the index
+// KEYS[6]. Jobs of the list KEYS[1] are scored like `promoteDelayedScript`
+// does, with ARGV[1] being the current Unix time in milliseconds and ARGV[2]
+// `priorityAging` in milliseconds. Jobs of the sorted sets KEYS[2] and KEYS[3]
+// keep their score. It returns the number of jobs moved.
+var migrateLegacyScript = redis.NewScript(jobIndexLua + `
+local now = tonumber(ARGV[1])
+local aging = tonumber(ARGV[2])
+local moved = 0
+local job = redis.call('RPOP', KEYS[1])
+while job do
+	local priority = 0
+	local ok, decoded = pcall(cjson.decode, job)
+	if ok and type(decoded) == 'table' and type(decoded.priority) == 'number' then
+		priority = decoded.priority
+	end
+	add_job(KEYS[6], KEYS[4], KEYS[5], KEYS[4], now - priority * aging, job)
+	moved = moved + 1
+	job = redis.call('RPOP', KEYS[1])
+end
+for i = 2, 3 do
+	local jobs = redis.call('ZRANGE', KEYS[i], 0, -1, 'WITHSCORES')
+	for j = 1, #jobs, 2 do
+		add_job(KEYS[6], KEYS[4], KEYS[5], KEYS[i + 2], jobs[j + 1], jobs[j])
+		moved = moved + 1
+	end
+	redis.call('DEL', KEYS[i])
+end
+return moved
+`)
+
+// RedisQueue is a `Queue` stored in Redis, which can be shared by any number
+// of instances.
+//
+// Each queue is a sorted set of jobs, alongside a sorted set of delayed jobs
+// and a hash indexing the jobs of both sets by execution ID, so that the job
+// of an execution is found without scanning the sets. A queue holds at most
+// one job per execution: adding a job replaces the job of the same execution
+// waiting in the queue or its delayed set. Dequeued jobs are moved into a processing list owned by the instance and
+// only removed from it once acknowledged. Every instance refreshes a liveness
+// key while it holds jobs; once it expires, another instance moves the jobs
+// of the dead instance back to their queue.
+type RedisQueue struct {
+	client *redis.Client
+	// instanceID identifies the processing lists of this instance.
+	instanceID string
+
+	// queues holds the names of the queues served by this instance, as
+	// passed to `Start`.
+	queues []string
+	// stopKeepAlive stops refreshing this instance's liveness. It is only
+	// called by `Release`, as other instances must not recover the jobs of
+	// this instance while it is still finishing them.
+	stopKeepAlive context.CancelFunc
+	mu            sync.Mutex
+}
+
+// NewRedisQueue creates a new `RedisQueue` backed by the provided Redis
+// client.
+func NewRedisQueue(client *redis.Client) *RedisQueue {
+	return &RedisQueue{client: client, instanceID: newWorkerID()}
+}
+
+// queueKey returns the key of the given queue.
+func queueKey(queue string) string {
+	return queueKeyPrefix + queue
+}
+
+// delayedKey returns the key of the delayed set of the given queue.
+func delayedKey(queue string) string {
+	return delayedKeyPrefix + queue
+}
+
+// indexKey returns the key of the index of the given queue.
+func indexKey(queue string) string {
+	return indexKeyPrefix + queue
+}
+
+// wakeKey returns the key of the wake-up list of the given queue.
+func wakeKey(queue string) string {
+	return wakeKeyPrefix + queue
+}
+
+// jobKeys returns the keys of the given queue, its delayed set and its index,
+// in the order the scripts expect them.
+func jobKeys(queue string) []string {
+	return []string{queueKey(queue), delayedKey(queue), indexKey(queue)}
+}
+
+// workerQueue identifies the processing list of the given instance for the
+// given queue, as "<instanceID>:<queue>". Instance IDs never contain a colon.
+func workerQueue(instanceID, queue string) string {
+	return instanceID + ":" + queue
+}
+
+// parseWorkerQueue splits the identifier of a processing list into the
+// instance ID and the queue. Identifiers without a queue were registered by
+// versions without named queues, whose jobs all belong to the default queue.
+func parseWorkerQueue(id string) (string, string) {
+	instanceID, queue, ok := strings.Cut(id, ":")
+	if !ok {
+		return id, models.DefaultQueue
+	}
+	return instanceID, queue
+}
+
+// processingKey returns the key of the processing list with the given
+// identifier, as returned by `workerQueue`.
+func processingKey(workerQueue string) string {
+	return processingKeyPrefix + workerQueue
+}
+
+// Enqueue adds a job to the sorted set of the named queue.
+func (q *RedisQueue) Enqueue(ctx context.Context, queue string, job string) error {
+	args := []any{1, queueScore(time.Now(), jobPriority(job)), job}
+	if err := addJobScript.Run(ctx, q.client, jobKeys(queue), args...).Err(); err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	q.wake(ctx, queue, 1)
+	return nil
+}
+
+// Delay adds a job to the delayed set of the named queue, from which it is
+// moved into the queue once `runAt` has passed.
+func (q *RedisQueue) Delay(ctx context.Context, queue string, job string, runAt time.Time) error {
+	args := []any{2, float64(runAt.UnixMilli()), job}
+	if err := addJobScript.Run(ctx, q.client, jobKeys(queue), args...).Err(); err != nil {
+		return fmt.Errorf("failed to enqueue delayed job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue atomically moves the job that is first in line from the named queue
+// into this instance's processing list for the queue. The job stays there
+// until it is acknowledged with `Ack`, so that it survives a crash of this
+// instance.
+//
+// While the queue is empty, Dequeue blocks on the wake-up list of the queue,
+// onto which a wake-up is pushed whenever a job is made runnable. A wake-up
+// may be taken by a call that then loses the job to another one, or be lost
+// if pushing it fails, so the queue is checked again after at most
+// `dequeueWaitTimeout` regardless. This also bounds how long Dequeue takes to
+// return once its context is done, as blocked Redis commands are not
+// interrupted by it.
+func (q *RedisQueue) Dequeue(ctx context.Context, queue string) (string, error) {
+	keys := []string{queueKey(queue), processingKey(workerQueue(q.instanceID, queue)), indexKey(queue)}
+	for {
+		job, err := dequeueScript.Run(ctx, q.client, keys).Text()
+		if err != redis.Nil {
+			return job, err
+		}
+
+		err = q.client.BLPop(ctx, dequeueWaitTimeout, wakeKey(queue)).Err()
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil && err != redis.Nil {
+			return "", fmt.Errorf("failed to wait for jobs: %w", err)
+		}
+	}
+}
+
+// Ack removes a job from this instance's processing list for the named queue.
+func (q *RedisQueue) Ack(ctx context.Context, queue string, job string) error {
+	key := processingKey(workerQueue(q.instanceID, queue))
+	if err := q.client.LRem(ctx, key, 1, job).Err(); err != nil {
+		return fmt.Errorf("failed to acknowledge job: %w", err)
+	}
+	return nil
+}
+
+// Nack moves a job from this instance's processing list back to the front of
+// the named queue.
+func (q *RedisQueue) Nack(ctx context.Context, queue string, job string) error {
+	keys := append([]string{processingKey(workerQueue(q.instanceID, queue))}, jobKeys(queue)...)
+	if err := nackScript.Run(ctx, q.client, keys, job).Err(); err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	q.wake(ctx, queue, 1)
+	return nil
+}
+
+// Remove removes the job of the given execution from the named queue or its
+// delayed set, as found in the index of the queue.
+func (q *RedisQueue) Remove(ctx context.Context, queue string, executionID uuid.UUID) error {
+	if err := removeScript.Run(ctx, q.client, jobKeys(queue), executionID.String()).Err(); err != nil {
+		return fmt.Errorf("failed to remove job: %w", err)
+	}
+	return nil
+}
+
+// Restore adds the jobs whose execution has no job in the index of the named
+// queue or in the processing lists of any instance for the queue.
+func (q *RedisQueue) Restore(ctx context.Context, queue string, jobs []string) (int, error) {
+	lists, err := q.client.SMembers(ctx, workersKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list workers: %w", err)
+	}
+	keys := jobKeys(queue)
+	for _, id := range lists {
+		if _, listQueue := parseWorkerQueue(id); listQueue == queue {
+			keys = append(keys, processingKey(id))
+		}
+	}
+
+	restored := 0
+	for batch := range slices.Chunk(jobs, restoreBatchSize) {
+		now := time.Now()
+		args := make([]any, 0, 4*len(batch))
+		for _, job := range batch {
+			if runAt := jobRunAt(job); runAt.After(now) {
+				args = append(args, jobExecutionID(job).String(), 2, float64(runAt.UnixMilli()), job)
+			} else {
+				args = append(args, jobExecutionID(job).String(), 1, queueScore(now, jobPriority(job)), job)
+			}
+		}
+		added, err := restoreScript.Run(ctx, q.client, keys, args...).Int()
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore jobs: %w", err)
+		}
+		restored += added
+	}
+	q.wake(ctx, queue, restored)
+	return restored, nil
+}
+
+// Start registers the processing lists of this instance for the named queues
+// and launches the goroutines that move due delayed jobs into these queues
+// and recover the jobs held by dead instances, until the provided context is
+// canceled. The liveness of this instance is refreshed until `Release` is
+// called.
+//
+// Beforehand, the jobs left by older versions, which had a single queue, are
+// moved into the default queue, so that they are not orphaned by an upgrade,
+// and the jobs of the named queues added before queues had an index are
+// indexed.
+func (q *RedisQueue) Start(ctx context.Context, queues []string) {
+	q.migrateLegacyJobs(ctx)
+	for _, queue := range queues {
+		q.indexJobs(ctx, queue)
+	}
+
+	aliveCtx, stopKeepAlive := context.WithCancel(context.WithoutCancel(ctx))
+	q.mu.Lock()
+	q.queues = queues
+	q.stopKeepAlive = stopKeepAlive
+	q.mu.Unlock()
+
+	go q.keepAlive(aliveCtx, queues)
+	go q.recoverOrphanedJobs(ctx)
+	for _, queue := range queues {
+		go q.promoteDelayed(ctx, queue)
+	}
+}
+
+// Release stops refreshing the liveness of this instance, moves every job
+// left in its processing lists back to their queues and unregisters them.
+func (q *RedisQueue) Release(ctx context.Context) error {
+	q.mu.Lock()
+	queues, stopKeepAlive := q.queues, q.stopKeepAlive
+	q.mu.Unlock()
+	if stopKeepAlive == nil {
+		return nil
+	}
+	stopKeepAlive()
+
+	var errs []error
+	for _, queue := range queues {
+		id := workerQueue(q.instanceID, queue)
+		keys := []string{processingKey(id), queueKey(queue), workersKey, delayedKey(queue), indexKey(queue)}
+		moved, err := recoverProcessingScript.Run(ctx, q.client, keys, id).Int()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to release processing jobs of queue %s: %w", queue, err))
+			continue
+		}
+		q.wake(ctx, queue, moved)
+	}
+	if err := q.client.Del(ctx, workerAliveKeyPrefix+q.instanceID).Err(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove worker liveness: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Bury pushes an entry onto the dead-letter list.
+func (q *RedisQueue) Bury(ctx context.Context, entry string) error {
+	if err := q.client.LPush(ctx, deadLetterKey, entry).Err(); err != nil {
+		return fmt.Errorf("failed to dead-letter job: %w", err)
+	}
+	return nil
+}
+
+// DeadLetters returns every entry of the dead-letter list, newest first.
+func (q *RedisQueue) DeadLetters(ctx context.Context) ([]string, error) {
+	entries, err := q.client.LRange(ctx, deadLetterKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return entries, nil
+}
+
+// RemoveDeadLetter removes an entry from the dead-letter list.
+func (q *RedisQueue) RemoveDeadLetter(ctx context.Context, entry string) (bool, error) {
+	removed, err := q.client.LRem(ctx, deadLetterKey, 1, entry).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	return removed > 0, nil
+}
+
+// PurgeDeadLetters deletes the dead-letter list.
+func (q *RedisQueue) PurgeDeadLetters(ctx context.Context) error {
+	if err := q.client.Del(ctx, deadLetterKey).Err(); err != nil {
+		return fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+	return nil
+}
+
+// migrateLegacyJobs moves the jobs left in the keys of older versions into
+// the default queue and its delayed set.
+func (q *RedisQueue) migrateLegacyJobs(ctx context.Context) {
+	keys := []string{
+		legacyQueueKey,
+		legacyJobsKey,
+		legacyDelayedKey,
+		queueKey(models.DefaultQueue),
+		delayedKey(models.DefaultQueue),
+		indexKey(models.DefaultQueue),
+	}
+	args := []any{time.Now().UnixMilli(), priorityAging.Milliseconds()}
+	moved, err := migrateLegacyScript.Run(ctx, q.client, keys, args...).Int()
+	if err != nil {
+		log.Errorf("Failed to migrate jobs of older versions: %v", err)
+		return
+	}
+	if moved > 0 {
+		log.Infof("Moved %d jobs of older versions into queue %s", moved, models.DefaultQueue)
+		q.wake(ctx, models.DefaultQueue, moved)
+	}
+}
+
+// indexJobs records the jobs of the named queue and its delayed set in the
+// index of the queue, unless the queue has been indexed already.
+func (q *RedisQueue) indexJobs(ctx context.Context, queue string) {
+	keys := append(jobKeys(queue), indexedKeyPrefix+queue)
+	indexed, err := indexScript.Run(ctx, q.client, keys).Int()
+	if err != nil {
+		log.Errorf("Failed to index jobs of queue %s: %v", queue, err)
+		return
+	}
+	if indexed > 0 {
+		log.Infof("Indexed %d jobs of queue %s", indexed, queue)
+	}
+}
+
+// keepAlive registers the processing lists of this instance and periodically
+// refreshes its liveness key until the provided context is canceled. Once the
+// key expires, other instances consider this one dead and recover its
+// processing lists.
+func (q *RedisQueue) keepAlive(ctx context.Context, queues []string) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, queue := range queues {
+				pipe.SAdd(ctx, workersKey, workerQueue(q.instanceID, queue))
+			}
+			pipe.Set(ctx, workerAliveKeyPrefix+q.instanceID, time.Now().UTC().Unix(), leaseDuration)
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Failed to refresh worker liveness: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// promoteDelayed periodically moves due jobs from the delayed set of the given
+// queue into the queue until the provided context is canceled.
+func (q *RedisQueue) promoteDelayed(ctx context.Context, queue string) {
+	ticker := time.NewTicker(delayedPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			keys := []string{delayedKey(queue), queueKey(queue)}
+			args := []any{time.Now().UnixMilli(), delayedBatchSize, priorityAging.Milliseconds()}
+			promoted, err := promoteDelayedScript.Run(ctx, q.client, keys, args...).Int()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Errorf("Failed to promote delayed jobs of queue %s: %v", queue, err)
+				}
+				continue
+			}
+			q.wake(ctx, queue, promoted)
+		}
+	}
+}
+
+// recoverOrphanedJobs periodically moves the jobs left in the processing
+// lists of dead instances back to their queues, until the provided context is
+// canceled.
+//
+// Recovered jobs are not necessarily runnable: a job whose execution was
+// already running is skipped by the worker that dequeues it (the execution is
+// recovered through its expired lease instead), and a job whose execution has
+// finished is simply dropped.
+func (q *RedisQueue) recoverOrphanedJobs(ctx context.Context) {
+	ticker := time.NewTicker(recoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		q.recoverOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverOnce moves the jobs left in the processing lists of dead instances
+// back to their queues.
+func (q *RedisQueue) recoverOnce(ctx context.Context) {
+	lists, err := q.client.SMembers(ctx, workersKey).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorf("Failed to list workers: %v", err)
+		}
+		return
+	}
+
+	for _, id := range lists {
+		instanceID, queue := parseWorkerQueue(id)
+		if instanceID == q.instanceID {
+			continue
+		}
+		alive, err := q.client.Exists(ctx, workerAliveKeyPrefix+instanceID).Result()
+		if err != nil {
+			log.Errorf("Failed to check liveness of worker %s: %v", instanceID, err)
+			continue
+		}
+		if alive == 1 {
+			continue
+		}
+
+		keys := []string{processingKey(id), queueKey(queue), workersKey, delayedKey(queue), indexKey(queue)}
+		moved, err := recoverProcessingScript.Run(ctx, q.client, keys, id).Int()
+		if err != nil {
+			log.Errorf("Failed to recover jobs of worker %s on queue %s: %v", instanceID, queue, err)
+			continue
+		}
+		if moved > 0 {
+			log.Warnf("Recovered %d jobs of dead worker %s on queue %s", moved, instanceID, queue)
+			q.wake(ctx, queue, moved)
+		}
+	}
+}
+
+// wake pushes a wake-up onto the wake-up list of the named queue for each of
+// the given number of jobs made runnable on it, so that as many blocked calls
+// to `Dequeue` return. The list is capped, as wake-ups pile up while no call
+// is blocked. Failures are only logged, as blocked calls check their queue
+// again before long anyway.
+func (q *RedisQueue) wake(ctx context.Context, queue string, jobs int) {
+	if jobs <= 0 {
+		return
+	}
+	wakeUps := make([]any, min(jobs, wakeListSize))
+	for i := range wakeUps {
+		wakeUps[i] = 1
+	}
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, wakeKey(queue), wakeUps...)
+		pipe.LTrim(ctx, wakeKey(queue), 0, wakeListSize-1)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Warnf("Failed to wake up workers of queue %s: %v", queue, err)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// restoreInterval is how often pending executions missing from their
+	// queue are looked for.
+	restoreInterval = 5 * time.Minute
+	// restoreGracePeriod is how long a pending execution may go without a job
+	// before it is restored, as the instance that created it may be about to
+	// enqueue it.
+	restoreGracePeriod = time.Minute
+)
+
+// restorePendingJobs restores the jobs of pending executions that are missing
+// from their queue, once right away and then every `restoreInterval`, until
+// the provided context is canceled.
+func (s *ExecutionService) restorePendingJobs(ctx context.Context) {
+	ticker := time.NewTicker(restoreInterval)
+	defer ticker.Stop()
+
+	for {
+		s.restorePending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// restorePending enqueues again every pending execution of the functions
+// registered with this instance that has no job on its queue, e.g. because
+// the queue was lost or its job was never enqueued. The queue skips the
+// executions that already have a job, so that several instances may restore
+// the same executions at the same time.
+func (s *ExecutionService) restorePending(ctx context.Context) {
+	pending, err := s.repo.ListPending(time.Now().Add(-restoreGracePeriod).UTC())
+	if err != nil {
+		log.Errorf("Failed to list pending executions: %v", err)
+		return
+	}
+
+	jobs := make(map[string][]string)
+	for _, exec := range pending {
+		// The executions of other functions may belong to queues this
+		// instance does not know about.
+		f, ok := s.functions[exec.Trigger.FunctionName]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(exec)
+		if err != nil {
+			log.Errorf("Failed to marshal job %s: %v", exec.Execution.ID, err)
+			continue
+		}
+		jobs[f.options.Queue] = append(jobs[f.options.Queue], string(data))
+	}
+
+	for queue, queued := range jobs {
+		restored, err := s.queue.Restore(ctx, queue, queued)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("Failed to restore jobs of queue %s: %v", queue, err)
+			}
+			continue
+		}
+		if restored > 0 {
+			log.Warnf("Restored %d pending jobs missing from queue %s", restored, queue)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Pelfox/quego/internal/cron"
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+// schedulerInterval is how often the scheduler checks for due schedules.
+const schedulerInterval = time.Second
+
+// ErrScheduleNotFound is returned when an operation refers to a `Schedule`
+// that does not exist.
+var ErrScheduleNotFound = errors.New("the requested schedule does not exist")
+
+// ScheduleService provides operations related to `Schedule` entities and runs
+// the scheduler that fires them. Every time a schedule is due, a CRON trigger
+// is persisted through the `TriggerService` and processed by the
+// `ExecutionService`.
+type ScheduleService struct {
+	repo             *repositories.ScheduleRepository
+	triggerService   *TriggerService
+	executionService *ExecutionService
+
+	// stopScheduler stops the scheduler goroutine started by `Start`, and
+	// schedulerDone is closed once it has returned.
+	stopScheduler context.CancelFunc
+	schedulerDone chan struct{}
+}
+
+// NewScheduleService creates and returns a new `ScheduleService` instance
+// backed by the provided `ScheduleRepository`.
+func NewScheduleService(
+	repo *repositories.ScheduleRepository,
+	triggerService *TriggerService,
+	executionService *ExecutionService,
+) *ScheduleService {
+	return &ScheduleService{
+		repo:             repo,
+		triggerService:   triggerService,
+		executionService: executionService,
+	}
+}
+
+// Create validates and persists a new `Schedule`. The schedule's ID, creation
+// time and next run time are assigned by the service.
+//
+// It returns `ErrFunctionNotFound` if the target function is not registered,
+// and an error wrapping `cron.ErrInvalidExpression` if the expression cannot
+// be parsed.
+func (s *ScheduleService) Create(schedule *models.Schedule) error {
+	if !s.executionService.HasFunction(schedule.FunctionName) {
+		return ErrFunctionNotFound
+	}
+
+	now := time.Now().UTC()
+	schedule.ID = uuid.New()
+	schedule.CreatedAt = now
+
+	next, err := nextRun(schedule, now)
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = next
+	return s.repo.Create(schedule)
+}
+
+// GetByID retrieves a `Schedule` by its unique identifier. It returns nil
+// without an error if no such schedule exists.
+func (s *ScheduleService) GetByID(id uuid.UUID) (*models.Schedule, error) {
+	return s.repo.GetByID(id)
+}
+
+// ListAll retrieves all `Schedule` entities from the underlying repository.
+func (s *ScheduleService) ListAll() ([]*models.Schedule, error) {
+	return s.repo.ListAll()
+}
+
+// Update applies the changes made by `apply` to the schedule with the given
+// ID, recomputes its next run time and persists it. It returns the updated
+// schedule, or `ErrScheduleNotFound` if it does not exist.
+func (s *ScheduleService) Update(id uuid.UUID, apply func(schedule *models.Schedule)) (*models.Schedule, error) {
+	schedule, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, ErrScheduleNotFound
+	}
+
+	apply(schedule)
+	next, err := nextRun(schedule, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	schedule.NextRunAt = next
+
+	if err := s.repo.Update(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Delete removes the schedule with the given ID. It returns
+// `ErrScheduleNotFound` if it does not exist.
+func (s *ScheduleService) Delete(id uuid.UUID) error {
+	schedule, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if schedule == nil {
+		return ErrScheduleNotFound
+	}
+	return s.repo.Delete(id)
+}
+
+// Start launches the scheduler goroutine. Every `schedulerInterval` it fires
+// all due schedules and advances them to their next run time.
+//
+// Runs missed while no instance was running are not caught up: a schedule
+// that is overdue fires once, and its next run is computed from the current
+// time.
+//
+// The scheduler runs until the provided context is canceled or `Stop` is
+// called.
+func (s *ScheduleService) Start(ctx context.Context) {
+	ctx, s.stopScheduler = context.WithCancel(ctx)
+	s.schedulerDone = make(chan struct{})
+	go func() {
+		defer close(s.schedulerDone)
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.fireDue(time.Now().UTC())
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler started by `Start` and waits for it to return, so
+// that schedules being fired are fully fired before the database is closed.
+func (s *ScheduleService) Stop() {
+	if s.stopScheduler == nil {
+		return
+	}
+	s.stopScheduler()
+	<-s.schedulerDone
+}
+
+// fireDue fires every schedule that is due at `now`.
+func (s *ScheduleService) fireDue(now time.Time) {
+	due, err := s.repo.ListDue(now)
+	if err != nil {
+		log.Errorf("Failed to list due schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range due {
+		next, err := nextRun(schedule, now)
+		if err != nil {
+			log.Errorf("Failed to compute next run for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		claimed, err := s.repo.Advance(schedule.ID, *schedule.NextRunAt, now, next)
+		if err != nil {
+			log.Errorf("Failed to advance schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			// Another instance has already fired this run.
+			continue
+		}
+
+		trigger := models.Trigger{
+			TriggerType:  models.TriggerTypeCron,
+			FunctionName: schedule.FunctionName,
+			Payload:      schedule.Payload,
+			ScheduleID:   &schedule.ID,
+		}
+		execution, err := s.executionService.NewExecution(&trigger, time.Time{})
+		if err != nil {
+			log.Errorf("Failed to process trigger for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		if err := s.triggerService.Create(&trigger, execution); err != nil {
+			log.Errorf("Failed to create trigger for schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		if err := s.executionService.QueueExecution(&trigger, execution); err != nil {
+			log.Errorf("Failed to enqueue trigger for schedule %s: %v", schedule.ID, err)
+			if err := s.triggerService.Discard(*trigger.ID); err != nil {
+				log.Errorf("Failed to discard trigger %s: %v", *trigger.ID, err)
+			}
+			continue
+		}
+	}
+}
+
+// nextRun computes the next run time of a schedule after `now`. It returns
+// nil for disabled schedules and for expressions that never match.
+func nextRun(schedule *models.Schedule, now time.Time) (*time.Time, error) {
+	parsed, err := cron.Parse(schedule.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if !schedule.Enabled {
+		return nil, nil
+	}
+
+	next := parsed.Next(now)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/internal/cron"
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/models"
+)
+
+// scheduleTest holds a `ScheduleService` firing the schedules of function "fn"
+// onto a `MemoryQueue`.
+type scheduleTest struct {
+	service *ScheduleService
+	repo    *repositories.ScheduleRepository
+	queue   *MemoryQueue
+}
+
+// newScheduleTest returns a `ScheduleService` backed by a new database.
+func newScheduleTest(t *testing.T) *scheduleTest {
+	t.Helper()
+	db := newSQLiteQueueTest(t)
+	queue := NewMemoryQueue()
+	executions := NewExecutionService(map[string]int{testQueue: 1}, queue, NewMemoryCoordinator(), db.executions)
+	executions.RegisterFunction("fn", func(context.Context, *models.Trigger) (any, error) {
+		return nil, nil
+	}, models.WithQueue(testQueue))
+
+	repo := repositories.NewScheduleRepository(db.db)
+	return &scheduleTest{
+		service: NewScheduleService(repo, NewTriggerService(db.triggers), executions),
+		repo:    repo,
+		queue:   queue,
+	}
+}
+
+// newSchedule creates an enabled schedule of "fn" with the given expression
+// and moves its next run to `nextRunAt`.
+func (s *scheduleTest) newSchedule(t *testing.T, expression string, nextRunAt time.Time) *models.Schedule {
+	t.Helper()
+	schedule := &models.Schedule{FunctionName: "fn", Expression: expression, Payload: "{}", Enabled: true}
+	if err := s.service.Create(schedule); err != nil {
+		t.Fatal(err)
+	}
+	schedule.NextRunAt = &nextRunAt
+	if err := s.repo.Update(schedule); err != nil {
+		t.Fatal(err)
+	}
+	return schedule
+}
+
+// schedule returns the stored schedule with the given ID.
+func (s *scheduleTest) schedule(t *testing.T, schedule *models.Schedule) *models.Schedule {
+	t.Helper()
+	stored, err := s.repo.GetByID(schedule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil {
+		t.Fatal("schedule not found")
+	}
+	return stored
+}
+
+// expectFired dequeues a job from the test queue, failing the test unless it
+// was fired by the given schedule.
+func (s *scheduleTest) expectFired(t *testing.T, schedule *models.Schedule) {
+	t.Helper()
+	var payload models.ExecutionWithTrigger
+	if err := json.Unmarshal([]byte(dequeueWithin(t, s.queue, 2*schedulerInterval)), &payload); err != nil {
+		t.Fatal(err)
+	}
+	trigger := payload.Trigger
+	if trigger.TriggerType != models.TriggerTypeCron || trigger.ScheduleID == nil || *trigger.ScheduleID != schedule.ID {
+		t.Fatalf("got %s trigger of schedule %v, want %s trigger of schedule %s",
+			trigger.TriggerType, trigger.ScheduleID, models.TriggerTypeCron, schedule.ID)
+	}
+}
+
+// expectAdvanced fails the test unless the given schedule last ran at
+// `lastRun` and runs next at `next`.
+func (s *scheduleTest) expectAdvanced(t *testing.T, schedule *models.Schedule, lastRun time.Time, next time.Time) {
+	t.Helper()
+	stored := s.schedule(t, schedule)
+	if stored.LastRunAt == nil || !stored.LastRunAt.Equal(lastRun) {
+		t.Fatalf("got last run at %v, want %s", stored.LastRunAt, lastRun)
+	}
+	if stored.NextRunAt == nil || !stored.NextRunAt.Equal(next) {
+		t.Fatalf("got next run at %v, want %s", stored.NextRunAt, next)
+	}
+}
+
+func TestScheduleServiceFireDue(t *testing.T) {
+	s := newScheduleTest(t)
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	due := s.newSchedule(t, "0 * * * *", now)
+	later := s.newSchedule(t, "0 * * * *", now.Add(time.Hour))
+
+	s.service.fireDue(now)
+	s.expectFired(t, due)
+	expectEmpty(t, s.queue, 10*time.Millisecond)
+	s.expectAdvanced(t, due, now, now.Add(time.Hour))
+	if stored := s.schedule(t, later); stored.LastRunAt != nil {
+		t.Fatalf("schedule that is not due last ran at %s", stored.LastRunAt)
+	}
+
+	// The schedule is not fired again before its next run.
+	s.service.fireDue(now.Add(time.Minute))
+	expectEmpty(t, s.queue, 10*time.Millisecond)
+}
+
+func TestScheduleServiceMissedRuns(t *testing.T) {
+	s := newScheduleTest(t)
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	schedule := s.newSchedule(t, "0 * * * *", now.Add(-5*time.Hour))
+
+	// Missed runs are not caught up: the schedule fires once and runs next
+	// after the current time.
+	s.service.fireDue(now)
+	s.expectFired(t, schedule)
+	expectEmpty(t, s.queue, 10*time.Millisecond)
+	s.expectAdvanced(t, schedule, now, time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC))
+}
+
+func TestScheduleServiceFiresOnce(t *testing.T) {
+	s := newScheduleTest(t)
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	schedule := s.newSchedule(t, "0 * * * *", now)
+	// Instances share the database the schedules are stored in.
+	other := NewScheduleService(s.repo, s.service.triggerService, s.service.executionService)
+
+	var wg sync.WaitGroup
+	for _, service := range []*ScheduleService{s.service, other} {
+		wg.Go(func() { service.fireDue(now) })
+	}
+	wg.Wait()
+	s.expectFired(t, schedule)
+	expectEmpty(t, s.queue, 10*time.Millisecond)
+}
+
+func TestScheduleServiceStop(t *testing.T) {
+	s := newScheduleTest(t)
+	schedule := s.newSchedule(t, "* * * * *", time.Now().UTC())
+	s.service.Start(context.Background())
+	s.expectFired(t, schedule)
+
+	// Once stopped, the scheduler no longer uses the database, which the
+	// test closes on cleanup.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.service.Stop()
+	}()
+	waitFor(t, stopped, time.Second)
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	next := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expression string
+		disabled   bool
+		want       *time.Time
+		wantErr    error
+	}{
+		{
+			name:       "enabled",
+			expression: "0 * * * *",
+			want:       &next,
+		},
+		{
+			name:       "disabled",
+			expression: "0 * * * *",
+			disabled:   true,
+		},
+		{
+			name:       "never matches",
+			expression: "0 0 30 2 *",
+		},
+		{
+			name:       "invalid",
+			expression: "0 * * *",
+			disabled:   true,
+			wantErr:    cron.ErrInvalidExpression,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextRun(&models.Schedule{Expression: tt.expression, Enabled: !tt.disabled}, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.want == nil && got != nil:
+				t.Fatalf("got next run at %s, want none", got)
+			case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+				t.Fatalf("got next run at %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+)
+
+// staleClaimAge is how long a job may stay claimed while its execution is
+// still pending before the claim is considered abandoned. Workers start the
+// execution of a claimed job right away, or return the job to its queue, so
+// such a claim belongs to an instance that died in between.
+const staleClaimAge = leaseDuration
+
+// dequeuePollInterval is how often an empty queue is checked for new jobs, as
+// SQLite cannot notify other instances sharing the database of them.
+const dequeuePollInterval = 100 * time.Millisecond
+
+// SQLiteQueue is a `Queue` kept in the `executions` table of the SQLite
+// database, so that no other server is needed. It can be shared by the
+// instances using the same database file.
+//
+// Every execution carries the state of its job: the queue it is on, its score
+// and, for delayed jobs, when it becomes available. Workers claim the pending
+// job with the lowest score with a single `UPDATE` statement, so that no two
+// instances claim the same job, and keep it claimed until it is acknowledged.
+type SQLiteQueue struct {
+	executions  *repositories.SQLiteExecutionRepository
+	deadLetters *repositories.DeadLetterRepository
+	// instanceID identifies the claims of this instance.
+	instanceID string
+}
+
+// NewSQLiteQueue creates a new `SQLiteQueue` backed by the provided
+// repositories.
+func NewSQLiteQueue(
+	executions *repositories.SQLiteExecutionRepository,
+	deadLetters *repositories.DeadLetterRepository,
+) *SQLiteQueue {
+	return &SQLiteQueue{
+		executions:  executions,
+		deadLetters: deadLetters,
+		instanceID:  newWorkerID(),
+	}
+}
+
+// Enqueue adds the job's execution to the named queue.
+func (q *SQLiteQueue) Enqueue(_ context.Context, queue string, job string) error {
+	id, err := sqliteJobID(job)
+	if err != nil {
+		return err
+	}
+	if err := q.executions.EnqueueJob(id, queue, queueScore(time.Now(), jobPriority(job)), nil); err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return nil
+}
+
+// Delay adds the job's execution to the named queue, but keeps it from being
+// claimed before `runAt`. It is scored as if it had been enqueued at `runAt`.
+func (q *SQLiteQueue) Delay(_ context.Context, queue string, job string, runAt time.Time) error {
+	id, err := sqliteJobID(job)
+	if err != nil {
+		return err
+	}
+	availableAt := runAt.UTC()
+	if err := q.executions.EnqueueJob(id, queue, queueScore(runAt, jobPriority(job)), &availableAt); err != nil {
+		return fmt.Errorf("failed to enqueue delayed job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue claims the job that is first in line on the named queue, polling
+// the database until one is available.
+func (q *SQLiteQueue) Dequeue(ctx context.Context, queue string) (string, error) {
+	for {
+		claimed, err := q.executions.ClaimJob(queue, q.instanceID, time.Now().UTC())
+		if err != nil {
+			return "", fmt.Errorf("failed to claim job: %w", err)
+		}
+		if claimed != nil {
+			job, err := json.Marshal(claimed)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal job: %w", err)
+			}
+			return string(job), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(dequeuePollInterval):
+		}
+	}
+}
+
+// Ack removes the job's execution from its queue, unless it has been enqueued
+// again since it was claimed.
+func (q *SQLiteQueue) Ack(_ context.Context, _ string, job string) error {
+	id, err := sqliteJobID(job)
+	if err != nil {
+		return err
+	}
+	if err := q.executions.AckJob(id, q.instanceID); err != nil {
+		return fmt.Errorf("failed to acknowledge job: %w", err)
+	}
+	return nil
+}
+
+// Nack releases the claim on the job and moves it to the front of its queue.
+func (q *SQLiteQueue) Nack(_ context.Context, _ string, job string) error {
+	id, err := sqliteJobID(job)
+	if err != nil {
+		return err
+	}
+	if err := q.executions.ReturnJob(id, q.instanceID, math.Inf(-1)); err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	return nil
+}
+
+// Remove removes the execution's job from the named queue, unless it has been
+// claimed already.
+func (q *SQLiteQueue) Remove(_ context.Context, queue string, executionID uuid.UUID) error {
+	return q.executions.RemoveJob(executionID, queue)
+}
+
+// Restore adds the executions of the jobs to the named queue, unless they are
+// on a queue already.
+func (q *SQLiteQueue) Restore(_ context.Context, queue string, jobs []string) (int, error) {
+	now := time.Now()
+	restored := 0
+	for _, job := range jobs {
+		id, err := sqliteJobID(job)
+		if err != nil {
+			return restored, err
+		}
+
+		var availableAt *time.Time
+		score := queueScore(now, jobPriority(job))
+		if runAt := jobRunAt(job); runAt.After(now) {
+			runAt = runAt.UTC()
+			availableAt = &runAt
+			score = queueScore(runAt, jobPriority(job))
+		}
+		added, err := q.executions.RestoreJob(id, queue, score, availableAt)
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore job: %w", err)
+		}
+		if added {
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+// Start launches the goroutine that releases the claims abandoned by dead
+// instances, until the provided context is canceled. Delayed jobs need no
+// background work, as they are claimed once available.
+func (q *SQLiteQueue) Start(ctx context.Context, _ []string) {
+	go q.recoverStaleClaims(ctx)
+}
+
+// Release releases every claim of this instance, moving the jobs to the front
+// of their queue.
+func (q *SQLiteQueue) Release(context.Context) error {
+	if err := q.executions.ReturnClaimedJobs(q.instanceID, math.Inf(-1)); err != nil {
+		return fmt.Errorf("failed to release claimed jobs: %w", err)
+	}
+	return nil
+}
+
+// Bury inserts an entry into the dead-letter table.
+func (q *SQLiteQueue) Bury(_ context.Context, entry string) error {
+	if err := q.deadLetters.Create(entry); err != nil {
+		return fmt.Errorf("failed to dead-letter job: %w", err)
+	}
+	return nil
+}
+
+// DeadLetters returns every entry of the dead-letter table, newest first.
+func (q *SQLiteQueue) DeadLetters(context.Context) ([]string, error) {
+	entries, err := q.deadLetters.ListAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	return entries, nil
+}
+
+// RemoveDeadLetter deletes an entry from the dead-letter table.
+func (q *SQLiteQueue) RemoveDeadLetter(_ context.Context, entry string) (bool, error) {
+	removed, err := q.deadLetters.Delete(entry)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	return removed, nil
+}
+
+// PurgeDeadLetters empties the dead-letter table.
+func (q *SQLiteQueue) PurgeDeadLetters(context.Context) error {
+	if err := q.deadLetters.DeleteAll(); err != nil {
+		return fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+	return nil
+}
+
+// recoverStaleClaims periodically moves the jobs whose claim has been
+// abandoned back to the front of their queue, until the provided context is
+// canceled. Jobs whose execution was already running are recovered through
+// their expired lease instead.
+func (q *SQLiteQueue) recoverStaleClaims(ctx context.Context) {
+	ticker := time.NewTicker(recoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		released, err := q.executions.ReturnStaleJobs(time.Now().Add(-staleClaimAge).UTC(), math.Inf(-1))
+		if err != nil {
+			log.Errorf("Failed to release stale claims: %v", err)
+		} else if released > 0 {
+			log.Warnf("Released %d jobs claimed by dead instances", released)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sqliteJobID returns the ID of the execution a job belongs to. Unlike other
+// queues, a `SQLiteQueue` keeps nothing but the execution, so a job that
+// cannot be decoded cannot be queued.
+func sqliteJobID(job string) (uuid.UUID, error) {
+	id := jobExecutionID(job)
+	if id == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("failed to decode job %q", job)
+	}
+	return id, nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/internal"
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteQueueTest holds a migrated database along with the repositories a
+// `SQLiteQueue` is built from.
+type sqliteQueueTest struct {
+	db          *sqlx.DB
+	executions  *repositories.SQLiteExecutionRepository
+	triggers    *repositories.SQLiteTriggerRepository
+	deadLetters *repositories.DeadLetterRepository
+}
+
+// newSQLiteQueueTest migrates a new database in a temporary directory.
+func newSQLiteQueueTest(t *testing.T) *sqliteQueueTest {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "quego.db")
+	if err := internal.MigrateDatabase(path); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &sqliteQueueTest{
+		db:          db,
+		executions:  repositories.NewSQLiteExecutionRepository(db),
+		triggers:    repositories.NewSQLiteTriggerRepository(db),
+		deadLetters: repositories.NewDeadLetterRepository(db),
+	}
+}
+
+// newQueue returns a new instance of the queue stored in the database.
+func (s *sqliteQueueTest) newQueue() *SQLiteQueue {
+	return NewSQLiteQueue(s.executions, s.deadLetters)
+}
+
+// newJob stores a pending execution with the given priority and returns its
+// job.
+func (s *sqliteQueueTest) newJob(t *testing.T, priority int) string {
+	t.Helper()
+	triggerID, executionID := uuid.New(), uuid.New()
+	trigger := &models.Trigger{
+		ID:           &triggerID,
+		TriggerType:  models.TriggerTypeEvent,
+		FunctionName: "fn",
+		Payload:      "{}",
+		Priority:     priority,
+	}
+	execution := &models.Execution{
+		ID:        executionID,
+		Status:    models.ExecutionStatusPending,
+		TriggerID: triggerID,
+		Attempt:   1,
+		Priority:  priority,
+	}
+	if _, err := s.triggers.CreateWithExecution(trigger, execution, 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(models.ExecutionWithTrigger{Execution: *execution, Trigger: *trigger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// expectExecutions dequeues as many jobs as given from the test queue,
+// failing the test unless they belong to the executions of the given jobs, in
+// the same order.
+func expectExecutions(t *testing.T, queue Queue, want ...string) []string {
+	t.Helper()
+	jobs := make([]string, len(want))
+	for i, job := range want {
+		jobs[i] = dequeueWithin(t, queue, time.Second)
+		if got, want := jobExecutionID(jobs[i]), jobExecutionID(job); got != want {
+			t.Fatalf("job %d: got execution %s, want %s", i, got, want)
+		}
+	}
+	return jobs
+}
+
+func TestSQLiteQueueClaim(t *testing.T) {
+	test := newSQLiteQueueTest(t)
+	queue, other := test.newQueue(), test.newQueue()
+	ctx := context.Background()
+	low, high, delayed := test.newJob(t, 0), test.newJob(t, 5), test.newJob(t, 10)
+	for _, job := range []string{low, high} {
+		if err := queue.Enqueue(ctx, testQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Delay(ctx, testQueue, delayed, time.Now().Add(300*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A job is claimed by a single instance.
+	expectExecutions(t, queue, high)
+	expectExecutions(t, other, low)
+	expectEmpty(t, queue, 50*time.Millisecond)
+	expectExecutions(t, other, delayed)
+}
+
+func TestSQLiteQueueAckAndNack(t *testing.T) {
+	test := newSQLiteQueueTest(t)
+	queue := test.newQueue()
+	ctx := context.Background()
+	first, second := test.newJob(t, 0), test.newJob(t, 0)
+	for _, job := range []string{first, second} {
+		if err := queue.Enqueue(ctx, testQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed := expectExecutions(t, queue, first)
+	if err := queue.Nack(ctx, testQueue, claimed[0]); err != nil {
+		t.Fatal(err)
+	}
+	claimed = expectExecutions(t, queue, first, second)
+
+	if err := queue.Ack(ctx, testQueue, claimed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Only the job that was not acknowledged is released.
+	expectExecutions(t, queue, second)
+	expectEmpty(t, queue, 50*time.Millisecond)
+}
+
+func TestSQLiteQueueStaleClaims(t *testing.T) {
+	test := newSQLiteQueueTest(t)
+	dead, alive := test.newQueue(), test.newQueue()
+	ctx := context.Background()
+	pending, running := test.newJob(t, 0), test.newJob(t, 0)
+	for _, job := range []string{pending, running} {
+		if err := dead.Enqueue(ctx, testQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectExecutions(t, dead, pending, running)
+	started, err := test.executions.Acquire(jobExecutionID(running), "worker", time.Now().Add(time.Minute).UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !started {
+		t.Fatal("execution was not acquired")
+	}
+
+	// The claims of the dead instance are abandoned long enough ago.
+	claimedAt := time.Now().Add(-2 * staleClaimAge).UTC()
+	if _, err := test.db.Exec("UPDATE executions SET claimed_at = ?", claimedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	startCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	alive.Start(startCtx, []string{testQueue})
+	// Running executions are recovered through their lease instead.
+	expectExecutions(t, alive, pending)
+	expectEmpty(t, alive, 50*time.Millisecond)
+}
+
+func TestSQLiteQueueRestore(t *testing.T) {
+	test := newSQLiteQueueTest(t)
+	queue := test.newQueue()
+	ctx := context.Background()
+	queued, held, missing := test.newJob(t, 0), test.newJob(t, 0), test.newJob(t, 0)
+	if err := queue.Enqueue(ctx, testQueue, held); err != nil {
+		t.Fatal(err)
+	}
+	expectExecutions(t, queue, held)
+	if err := queue.Enqueue(ctx, testQueue, queued); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := queue.Restore(ctx, testQueue, []string{queued, held, missing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 1 {
+		t.Fatalf("restored %d jobs, want 1", restored)
+	}
+	expectExecutions(t, queue, queued, missing)
+	expectEmpty(t, queue, 50*time.Millisecond)
+}
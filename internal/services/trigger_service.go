@@ -1,29 +1,130 @@
 package services
 
 import (
+	"errors"
+	"time"
+
 	"github.com/Pelfox/quego/internal/repositories"
 	"github.com/Pelfox/quego/models"
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrTriggerNotFound is returned when an operation refers to a `Trigger`
+	// that does not exist.
+	ErrTriggerNotFound = errors.New("the requested trigger does not exist")
+	// ErrTriggerActive is returned when deleting a `Trigger` that still has a
+	// pending or running execution.
+	ErrTriggerActive = errors.New("the trigger has a pending or running execution")
+)
+
 // TriggerService provides operations related to `Trigger` entities. It
 // uses an `TriggerRepository` for data persistence while serving as the main
 // access point for higher layers.
 type TriggerService struct {
-	repo *repositories.TriggerRepository
+	repo repositories.TriggerRepository
 }
 
 // NewTriggerService creates and returns a new `TriggerService` instance backed
 // by the provided `TriggerRepository`.
-func NewTriggerService(repo *repositories.TriggerRepository) *TriggerService {
+func NewTriggerService(repo repositories.TriggerRepository) *TriggerService {
 	return &TriggerService{repo: repo}
 }
 
-// Create persists a new `Trigger` in the underlying repository. The provided
-// `Trigger` may not have an ID assigned yet; in such cases, the service is
-// responsible for generating and assigning a unique ID before storing it.
-func (s *TriggerService) Create(trigger *models.Trigger) error {
+// Create persists a new `Trigger` along with its first `Execution`, as
+// returned by `ExecutionService.NewExecution`, in the underlying repository.
+// The service assigns the trigger a unique ID, and links the execution to it.
+func (s *TriggerService) Create(trigger *models.Trigger, execution *models.Execution) error {
 	triggerID := uuid.New()
 	trigger.ID = &triggerID
-	return s.repo.Create(trigger)
+	execution.TriggerID = triggerID
+	_, err := s.repo.CreateWithExecution(trigger, execution, 0)
+	return err
+}
+
+// CreateIdempotent behaves like `Create` for a `Trigger` carrying an
+// idempotency key, unless a trigger with the same key has already been
+// created within the given window. In that case, nothing is persisted and the
+// existing trigger is returned instead, along with false.
+func (s *TriggerService) CreateIdempotent(
+	trigger *models.Trigger,
+	execution *models.Execution,
+	window time.Duration,
+) (*models.Trigger, bool, error) {
+	triggerID := uuid.New()
+	trigger.ID = &triggerID
+	execution.TriggerID = triggerID
+
+	created, err := s.repo.CreateWithExecution(trigger, execution, window)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		return trigger, true, nil
+	}
+
+	existing, err := s.repo.GetByIdempotencyKey(*trigger.IdempotencyKey, window)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		// The existing trigger has left the window in the meantime.
+		return s.CreateIdempotent(trigger, execution, window)
+	}
+	return existing, false, nil
+}
+
+// Discard deletes a `Trigger` along with its executions, whatever their
+// status. It undoes `Create` when the execution could not be enqueued, so
+// that the trigger's idempotency key can be used again.
+func (s *TriggerService) Discard(id uuid.UUID) error {
+	return s.repo.Delete(id)
+}
+
+// GetByID retrieves the trigger with the given ID, or nil if it does not
+// exist.
+func (s *TriggerService) GetByID(id uuid.UUID) (*models.Trigger, error) {
+	return s.repo.GetByID(id)
+}
+
+// List retrieves the triggers matching the filter, newest first. If the
+// filter has a limit and more triggers match, it also returns the cursor
+// from which the next page can be retrieved.
+func (s *TriggerService) List(filter repositories.TriggerFilter) ([]*models.Trigger, *repositories.Cursor, error) {
+	limit := filter.Limit
+	if limit > 0 {
+		// Retrieve one more trigger to tell whether there is a next page.
+		filter.Limit++
+	}
+	triggers, err := s.repo.List(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if limit <= 0 || len(triggers) <= limit {
+		return triggers, nil, nil
+	}
+	triggers = triggers[:limit]
+	return triggers, repositories.TriggerCursor(triggers[limit-1]), nil
+}
+
+// Delete removes the trigger with the given ID along with its executions. It
+// returns `ErrTriggerNotFound` if it does not exist, and `ErrTriggerActive`
+// if one of its executions is still pending or running.
+func (s *TriggerService) Delete(id uuid.UUID) error {
+	deleted, err := s.repo.DeleteInactive(id)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		return nil
+	}
+
+	trigger, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if trigger == nil {
+		return ErrTriggerNotFound
+	}
+	return ErrTriggerActive
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// executionContextKey is the context key under which the running execution is
+// stored.
+type executionContextKey struct{}
+
+// executionContext describes the execution a context belongs to.
+type executionContext struct {
+	executionID uuid.UUID
+	attempt     int
+}
+
+// NewExecutionContext returns a copy of `parent` that carries the ID and the
+// attempt number of the execution it belongs to. It is used by the execution
+// service before invoking a function.
+func NewExecutionContext(parent context.Context, executionID uuid.UUID, attempt int) context.Context {
+	return context.WithValue(parent, executionContextKey{}, executionContext{
+		executionID: executionID,
+		attempt:     attempt,
+	})
+}
+
+// ExecutionIDFromContext returns the ID of the execution the context belongs
+// to. It reports false if the context does not belong to an execution.
+func ExecutionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	execution, ok := ctx.Value(executionContextKey{}).(executionContext)
+	return execution.executionID, ok
+}
+
+// AttemptFromContext returns the 1-based attempt number of the execution the
+// context belongs to. It returns 0 if the context does not belong to an
+// execution.
+func AttemptFromContext(ctx context.Context) int {
+	execution, _ := ctx.Value(executionContextKey{}).(executionContext)
+	return execution.attempt
+}
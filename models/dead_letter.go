@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetterReason describes why a job was moved to the dead-letter queue.
+type DeadLetterReason string
+
+const (
+	// DeadLetterReasonMalformedJob means the job could not be decoded, so it
+	// is not known which execution it belongs to.
+	DeadLetterReasonMalformedJob DeadLetterReason = "MALFORMED_JOB"
+	// DeadLetterReasonFunctionNotFound means the job refers to a function
+	// that is not registered.
+	DeadLetterReasonFunctionNotFound DeadLetterReason = "FUNCTION_NOT_FOUND"
+	// DeadLetterReasonRetriesExhausted means every attempt allowed by the
+	// function's retry policy has failed. Only the executions of functions
+	// allowed more than one attempt are dead-lettered this way; those of
+	// functions that are never retried end up `FAILED` or `TIMED_OUT`, as
+	// there is no retry policy to exhaust.
+	DeadLetterReasonRetriesExhausted DeadLetterReason = "RETRIES_EXHAUSTED"
+)
+
+// DeadLetter is a job that has been moved to the dead-letter queue instead of
+// being executed (again).
+type DeadLetter struct {
+	// ID is the unique identifier of this dead letter. It equals the ID of
+	// the execution the job belongs to, unless the job is malformed.
+	ID uuid.UUID `json:"id"`
+	// ExecutionID refers to the execution the job belongs to. It is nil for
+	// malformed jobs.
+	ExecutionID *uuid.UUID `json:"execution_id,omitempty"`
+	// Reason is the reason the job was dead-lettered.
+	Reason DeadLetterReason `json:"reason"`
+	// Error is the last error the job failed with, if any.
+	Error string `json:"error,omitempty"`
+	// Job is the raw job as it was taken off the queue.
+	Job string `json:"job"`
+	// DeadAt is the timestamp when the job was dead-lettered.
+	DeadAt time.Time `json:"dead_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"runtime/debug"
+)
+
+// StackTraceError is an error annotated with the stack trace of the goroutine
+// that created it. When a function fails with such an error, the stack trace
+// is stored on the execution alongside the error message.
+type StackTraceError struct {
+	// Err is the annotated error.
+	Err error
+	// StackTrace is the formatted stack trace, as returned by
+	// `debug.Stack`.
+	StackTrace string
+}
+
+// WithStackTrace annotates `err` with the stack trace of the calling
+// goroutine. It returns nil if `err` is nil.
+func WithStackTrace(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StackTraceError{Err: err, StackTrace: string(debug.Stack())}
+}
+
+// Error returns the message of the annotated error.
+func (e *StackTraceError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the annotated error.
+func (e *StackTraceError) Unwrap() error {
+	return e.Err
+}
+
+// StackTraceOf returns the stack trace attached to `err` or to any error in
+// its chain by `WithStackTrace`. It returns an empty string if there is none.
+func StackTraceOf(err error) string {
+	var stackErr *StackTraceError
+	if errors.As(err, &stackErr) {
+		return stackErr.StackTrace
+	}
+	return ""
+}
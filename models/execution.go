@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
 )
 
 // ExecutionStatus represents the lifecycle state of an Execution. It indicates
@@ -24,8 +25,45 @@ const (
 	// ExecutionStatusFailed means the execution has finished, but with an
 	// error or unexpected termination.
 	ExecutionStatusFailed ExecutionStatus = "FAILED"
+	// ExecutionStatusTimedOut means the execution has finished because it
+	// ran longer than the timeout of its function.
+	ExecutionStatusTimedOut ExecutionStatus = "TIMED_OUT"
+	// ExecutionStatusCancelled means the execution was cancelled on request
+	// before it could finish.
+	ExecutionStatusCancelled ExecutionStatus = "CANCELLED"
+	// ExecutionStatusSkipped means the execution never ran because another
+	// execution of the same function was already running, and the function
+	// is registered with `ConcurrencySkip`.
+	ExecutionStatusSkipped ExecutionStatus = "SKIPPED"
+	// ExecutionStatusDead means the execution could not be completed and
+	// will not be attempted again on its own: either its retries were
+	// exhausted, or its function is not registered. It has been moved to the
+	// dead-letter queue, from which it can be requeued manually.
+	ExecutionStatusDead ExecutionStatus = "DEAD"
 )
 
+// IsValid reports whether the status is one of the known statuses.
+func (s ExecutionStatus) IsValid() bool {
+	switch s {
+	case ExecutionStatusPending,
+		ExecutionStatusRunning,
+		ExecutionStatusCompleted,
+		ExecutionStatusFailed,
+		ExecutionStatusTimedOut,
+		ExecutionStatusCancelled,
+		ExecutionStatusSkipped,
+		ExecutionStatusDead:
+		return true
+	}
+	return false
+}
+
+// IsTerminal reports whether the status is final, i.e. the execution has
+// finished and will not change its status on its own anymore.
+func (s ExecutionStatus) IsTerminal() bool {
+	return s != ExecutionStatusPending && s != ExecutionStatusRunning
+}
+
 // Execution represents a single invocation attempt of a triggered function.
 //
 // An Execution record is created once an event trigger has been received and
@@ -40,14 +78,63 @@ type Execution struct {
 	// TriggerID refers to the originating trigger that caused this
 	// execution to be created.
 	TriggerID uuid.UUID `db:"trigger_id" json:"trigger_id"`
+	// Attempt is the 1-based number of the current attempt. It is
+	// incremented every time a failed execution is retried.
+	Attempt int `db:"attempt" json:"attempt"`
+	// Priority is copied from the trigger. Executions with a higher priority
+	// are dequeued first, although executions that have waited long enough
+	// are dequeued ahead of newer ones with a higher priority.
+	Priority int `db:"priority" json:"priority"`
 
+	// CreatedAt is the timestamp when the execution was created. Executions
+	// are listed in reverse creation order.
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	// ScheduledAt is the timestamp before which the execution does not
+	// start. It is nil for executions that were runnable right away.
+	ScheduledAt *time.Time `db:"scheduled_at" json:"scheduled_at,omitempty"`
 	// StartedAt is the timestamp when the execution actually began
 	// running. It is nil if the execution has not started yet.
 	StartedAt *time.Time `db:"started_at" json:"started_at,omitempty"`
 	// FinishedAt is the timestamp when the execution reached a terminal
-	// state (`Completed` or `Failed`). It is nil if the execution is still
-	// pending or running.
+	// state (`Completed`, `Failed`, `TimedOut`, `Cancelled`, `Skipped` or
+	// `Dead`). It is nil if the execution is still pending or running.
 	FinishedAt *time.Time `db:"finished_at" json:"finished_at,omitempty"`
+
+	// Result is the JSON-encoded value returned by the function of a
+	// completed execution. It is nil if the function returned no result.
+	Result *types.JSONText `db:"result" json:"result,omitempty"`
+	// Error is the error message of the last failed attempt. It is nil if no
+	// attempt has failed.
+	Error *string `db:"error" json:"error,omitempty"`
+	// StackTrace is the stack trace of the last failed attempt, if the
+	// function provided one through `WithStackTrace`.
+	StackTrace *string `db:"stack_trace" json:"stack_trace,omitempty"`
+
+	// WorkerID identifies the worker holding the lease on this execution. It
+	// is only set while the execution is running.
+	WorkerID *string `db:"worker_id" json:"worker_id,omitempty"`
+	// LeaseExpiresAt is the timestamp until which the worker may run this
+	// execution without renewing its lease. Once it has passed, the execution
+	// is considered abandoned and is requeued. It is only set while the
+	// execution is running.
+	LeaseExpiresAt *time.Time `db:"lease_expires_at" json:"lease_expires_at,omitempty"`
+
+	// The following fields hold the state of the execution's job when the
+	// queue is kept in SQLite, and are unused otherwise.
+
+	// Queue is the name of the queue the job was last enqueued on.
+	Queue string `db:"queue" json:"-"`
+	// QueueScore orders the job within its queue, lowest first. It is nil if
+	// the job is not queued.
+	QueueScore *float64 `db:"queue_score" json:"-"`
+	// AvailableAt is the timestamp before which a delayed job may not be
+	// dequeued. It is nil for jobs that are runnable right away.
+	AvailableAt *time.Time `db:"available_at" json:"-"`
+	// ClaimedBy identifies the instance that dequeued the job and has not
+	// acknowledged it yet.
+	ClaimedBy *string `db:"claimed_by" json:"-"`
+	// ClaimedAt is the timestamp when the job was dequeued by `ClaimedBy`.
+	ClaimedAt *time.Time `db:"claimed_at" json:"-"`
 }
 
 // ExecutionWithTrigger represents an execution along with its associated
@@ -59,9 +146,3 @@ type ExecutionWithTrigger struct {
 	// be created.
 	Trigger Trigger `db:"trigger" json:"trigger"`
 }
-
-type StaleExecution struct {
-	Payload      map[string]any
-	FunctionName string
-	ExecutionID  uuid.UUID
-}
@@ -1,5 +1,279 @@
 package models
 
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
 // ExecFunction represents an executable unit that can be triggered by the
 // execution service.
+//
+// ExecFunction does not receive a context, so it can neither be cancelled nor
+// learn about the execution it runs in. New functions should prefer
+// `ContextFunction`.
 type ExecFunction func(trigger *Trigger) error
+
+// WithContext adapts the function to the `ContextFunction` signature. The
+// returned function ignores its context.
+func (f ExecFunction) WithContext() ContextFunction {
+	return func(_ context.Context, trigger *Trigger) error {
+		return f(trigger)
+	}
+}
+
+// ContextFunction represents an executable unit that can be triggered by the
+// execution service. The context it receives is cancelled when the execution
+// must stop, carries the execution's deadline if it has one, and exposes the
+// execution ID and attempt number through `ExecutionIDFromContext` and
+// `AttemptFromContext`.
+type ContextFunction func(ctx context.Context, trigger *Trigger) error
+
+// WithResult adapts the function to the `ResultFunction` signature. The
+// returned function produces no result.
+func (f ContextFunction) WithResult() ResultFunction {
+	return func(ctx context.Context, trigger *Trigger) (any, error) {
+		return nil, f(ctx, trigger)
+	}
+}
+
+// ResultFunction represents an executable unit that can be triggered by the
+// execution service and produces a result. It receives the same context as a
+// `ContextFunction`. On success, the returned result is encoded as JSON and
+// stored on the execution; a nil result is not stored.
+type ResultFunction func(ctx context.Context, trigger *Trigger) (any, error)
+
+// RetryPolicy defines how failed executions of a function are retried. Each
+// retry waits for an exponentially growing backoff before the next attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 1 are treated as 1, meaning the execution is never
+	// retried. Executions that fail their last attempt are dead-lettered,
+	// unless they were allowed a single attempt.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after every
+	// attempt. Values below 1 are treated as 1, resulting in a constant
+	// backoff.
+	Multiplier float64
+	// MaxBackoff caps the backoff between attempts, jitter included. Zero
+	// means no cap.
+	MaxBackoff time.Duration
+	// Jitter randomizes each backoff by up to the given fraction in either
+	// direction (e.g. 0.1 for ±10%), so that executions failing together do
+	// not retry together.
+	Jitter float64
+}
+
+// Backoff returns the delay to wait after the given (1-based) attempt has
+// failed, before the next attempt starts.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := max(p.Multiplier, 1)
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(max(attempt, 1)-1))
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, float64(p.MaxBackoff))
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	// The cap applies after the jitter, which may otherwise exceed it.
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, float64(p.MaxBackoff))
+	}
+	return time.Duration(min(max(backoff, 0), math.MaxInt64))
+}
+
+// ConcurrencyMode defines what happens when an execution of a function is
+// about to start while another execution of the same function is running.
+// It is enforced across all workers and instances.
+type ConcurrencyMode string
+
+const (
+	// ConcurrencyAllow lets executions of the function run concurrently.
+	// This is the default.
+	ConcurrencyAllow ConcurrencyMode = "ALLOW"
+	// ConcurrencySkip skips the new execution, marking it as skipped.
+	ConcurrencySkip ConcurrencyMode = "SKIP"
+	// ConcurrencyQueue keeps the new execution pending until the running one
+	// has finished.
+	ConcurrencyQueue ConcurrencyMode = "QUEUE"
+	// ConcurrencyReplace cancels the running execution and starts the new one
+	// once the running one has stopped.
+	ConcurrencyReplace ConcurrencyMode = "REPLACE"
+)
+
+// DefaultQueue is the name of the queue functions are assigned to unless
+// registered with `WithQueue`.
+const DefaultQueue = "default"
+
+// FunctionOptions holds the settings a function was registered with.
+type FunctionOptions struct {
+	// Description is a human-readable summary of what the function does.
+	Description string
+	// Tags are free-form labels used to group functions, e.g. in the
+	// dashboard.
+	Tags []string
+	// PayloadSchema is a JSON Schema document describing the payload the
+	// function expects. It is informational and not enforced.
+	PayloadSchema json.RawMessage
+	// Retry is the policy applied when an execution of the function fails.
+	// By default, executions are not retried.
+	Retry RetryPolicy
+	// Timeout is the maximum duration of a single attempt. Once exceeded, the
+	// attempt's context is cancelled and it is treated as failed. Zero means
+	// no timeout.
+	Timeout time.Duration
+	// Concurrency defines how executions of the function that overlap with
+	// each other are handled. Defaults to `ConcurrencyAllow`.
+	Concurrency ConcurrencyMode
+	// MaxConcurrency is the maximum number of executions of the function
+	// running at once across all instances. Zero means no limit.
+	MaxConcurrency int
+	// RateLimits are the limits on how often executions of the function may
+	// start across all instances. By default, executions are not limited.
+	RateLimits []RateLimit
+	// Queue is the name of the queue the executions of the function are
+	// enqueued on. Defaults to `DefaultQueue`.
+	Queue string
+}
+
+// FunctionOption configures a function during registration.
+type FunctionOption func(options *FunctionOptions)
+
+// WithDescription sets the human-readable summary of what the function does.
+func WithDescription(description string) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.Description = description
+	}
+}
+
+// WithTags labels the function with the given tags. It may be given several
+// times to add more tags.
+func WithTags(tags ...string) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.Tags = append(options.Tags, tags...)
+	}
+}
+
+// WithPayloadSchema sets the JSON Schema document describing the payload the
+// function expects. The schema is only exposed to clients through
+// `GET /functions`; payloads are not validated against it.
+func WithPayloadSchema(schema json.RawMessage) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.PayloadSchema = schema
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed executions of the
+// function.
+func WithRetryPolicy(policy RetryPolicy) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.Retry = policy
+	}
+}
+
+// WithTimeout sets the maximum duration of a single execution attempt of the
+// function.
+func WithTimeout(timeout time.Duration) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.Timeout = timeout
+	}
+}
+
+// WithConcurrencyMode sets how overlapping executions of the function are
+// handled.
+func WithConcurrencyMode(mode ConcurrencyMode) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.Concurrency = mode
+	}
+}
+
+// WithMaxConcurrency limits how many executions of the function may run at
+// once across all instances. Executions over the limit stay pending until
+// one of the running executions finishes.
+func WithMaxConcurrency(limit int) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.MaxConcurrency = limit
+	}
+}
+
+// RateLimit caps how many executions of a function may start within a
+// sliding window of time, e.g. 10 per second.
+type RateLimit struct {
+	// Limit is the maximum number of executions started within `Period`.
+	Limit int
+	// Period is the length of the sliding window.
+	Period time.Duration
+}
+
+// WithRateLimit limits how many executions of the function may start within
+// the given period across all instances. Executions over the limit stay
+// pending until they fit within it. It may be given several times to combine
+// limits, e.g. 10 per second and 1000 per hour; of two limits with the same
+// period, the lowest is kept. Limits whose limit or period is not positive are
+// rejected when the function is registered.
+func WithRateLimit(limit int, period time.Duration) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.RateLimits = append(options.RateLimits, RateLimit{Limit: limit, Period: period})
+	}
+}
+
+// WithQueue assigns the function to the named queue. Each queue is served by
+// its own pool of workers, so that slow functions do not hold up others.
+func WithQueue(name string) FunctionOption {
+	return func(options *FunctionOptions) {
+		options.Queue = name
+	}
+}
+
+// FunctionInfo describes a registered function and its settings, along with
+// the number of its executions that are pending or running.
+type FunctionInfo struct {
+	// Name is the name the function was registered with.
+	Name string `json:"name"`
+	// Description is the human-readable summary of the function, if any.
+	Description string `json:"description,omitempty"`
+	// Tags are the labels of the function.
+	Tags []string `json:"tags"`
+	// PayloadSchema is the JSON Schema document describing the payload of
+	// the function, if any.
+	PayloadSchema json.RawMessage `json:"payload_schema,omitempty"`
+	// Queue is the name of the queue the function's executions are enqueued
+	// on.
+	Queue string `json:"queue"`
+	// Retry describes how failed executions of the function are retried.
+	Retry RetryInfo `json:"retry"`
+	// Timeout is the maximum duration of a single attempt, formatted like
+	// `time.Duration.String`. It is empty if attempts have no timeout.
+	Timeout string `json:"timeout,omitempty"`
+	// Concurrency defines how overlapping executions of the function are
+	// handled.
+	Concurrency ConcurrencyMode `json:"concurrency"`
+	// MaxConcurrency is the maximum number of executions of the function
+	// running at once. Zero means no limit.
+	MaxConcurrency int `json:"max_concurrency"`
+
+	// Pending is the number of executions of the function waiting to run.
+	Pending int `json:"pending"`
+	// Running is the number of executions of the function currently running.
+	Running int `json:"running"`
+}
+
+// RetryInfo describes a `RetryPolicy`, with its durations formatted like
+// `time.Duration.String`.
+type RetryInfo struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff string `json:"initial_backoff"`
+	// Multiplier is the factor by which the backoff grows after every attempt.
+	Multiplier float64 `json:"multiplier"`
+	// MaxBackoff caps the backoff between attempts. It is empty if there is
+	// no cap.
+	MaxBackoff string `json:"max_backoff,omitempty"`
+	// Jitter is the fraction by which each backoff is randomized.
+	Jitter float64 `json:"jitter"`
+}
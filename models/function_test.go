@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{
+			name:    "initial",
+			policy:  RetryPolicy{InitialBackoff: time.Second, Multiplier: 2},
+			attempt: 1,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name:    "exponential",
+			policy:  RetryPolicy{InitialBackoff: time.Second, Multiplier: 2},
+			attempt: 4,
+			min:     8 * time.Second,
+			max:     8 * time.Second,
+		},
+		{
+			name:    "constant below a multiplier of 1",
+			policy:  RetryPolicy{InitialBackoff: time.Second, Multiplier: 0.5},
+			attempt: 3,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name:    "capped",
+			policy:  RetryPolicy{InitialBackoff: time.Second, Multiplier: 2, MaxBackoff: 5 * time.Second},
+			attempt: 10,
+			min:     5 * time.Second,
+			max:     5 * time.Second,
+		},
+		{
+			name:    "jittered",
+			policy:  RetryPolicy{InitialBackoff: 10 * time.Second, Jitter: 0.1},
+			attempt: 1,
+			min:     9 * time.Second,
+			max:     11 * time.Second,
+		},
+		{
+			name: "jittered below the cap",
+			policy: RetryPolicy{
+				InitialBackoff: 10 * time.Second,
+				Multiplier:     2,
+				MaxBackoff:     30 * time.Second,
+				Jitter:         0.5,
+			},
+			attempt: 5,
+			min:     15 * time.Second,
+			max:     30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				backoff := tt.policy.Backoff(tt.attempt)
+				if backoff < tt.min || backoff > tt.max {
+					t.Fatalf("got backoff %s, want between %s and %s", backoff, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schedule describes a recurring execution of a function. Every time the
+// CRON expression of an enabled schedule fires, a `TriggerTypeCron` trigger is
+// created and its function is enqueued for execution.
+type Schedule struct {
+	// ID is the unique identifier of this schedule.
+	ID uuid.UUID `db:"id" json:"id"`
+	// FunctionName is the name of the function to be executed on every run.
+	FunctionName string `db:"function_name" json:"function_name"`
+	// Expression is the five-field CRON expression (or an `@daily`-style
+	// descriptor) that defines when the schedule fires. It is evaluated in
+	// UTC.
+	Expression string `db:"expression" json:"expression"`
+	// Payload is passed to the function with every trigger created by this
+	// schedule.
+	Payload string `db:"payload" json:"payload,omitempty"`
+	// Enabled reports whether the schedule is currently active. Disabled
+	// schedules are kept, but never fire.
+	Enabled bool `db:"enabled" json:"enabled"`
+
+	// NextRunAt is the next time the schedule fires. It is nil for disabled
+	// schedules and for expressions that never match.
+	NextRunAt *time.Time `db:"next_run_at" json:"next_run_at,omitempty"`
+	// LastRunAt is the last time the schedule fired. It is nil if the
+	// schedule never fired yet.
+	LastRunAt *time.Time `db:"last_run_at" json:"last_run_at,omitempty"`
+	// CreatedAt is the timestamp when the schedule was created.
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -18,6 +20,11 @@ const (
 	TriggerTypeEvent TriggerType = "EVENT"
 )
 
+// IsValid reports whether the trigger type is one of the known types.
+func (t TriggerType) IsValid() bool {
+	return t == TriggerTypeCron || t == TriggerTypeEvent
+}
+
 // Trigger describes a request to execute a function. It contains the trigger
 // type, the name of the function to be invoked, and payload data to be passed
 // into the function.
@@ -36,4 +43,24 @@ type Trigger struct {
 	// Payload contains the input data for the function execution. It must
 	// match the defined input schema of the target function.
 	Payload string `db:"payload" json:"payload,omitempty"`
+	// Priority is the priority of the executions created for this trigger.
+	// Executions with a higher priority are dequeued first. Defaults to 0.
+	Priority int `db:"priority" json:"priority"`
+	// ScheduleID refers to the `Schedule` that created this trigger. It is
+	// only set for `TriggerTypeCron` triggers.
+	ScheduleID *uuid.UUID `db:"schedule_id" json:"schedule_id,omitempty"`
+	// IdempotencyKey is the client-provided key used to detect repeated
+	// submissions of the same trigger. It is nil if no key was provided.
+	IdempotencyKey *string `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	// CreatedAt is the timestamp when the trigger was persisted. It is nil
+	// until then.
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+// TriggerWithExecutions represents a trigger along with every execution it
+// has spawned, newest first.
+type TriggerWithExecutions struct {
+	Trigger
+	// Executions holds the executions created for this trigger.
+	Executions []*Execution `json:"executions"`
 }
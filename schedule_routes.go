@@ -0,0 +1,186 @@
+package quego
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Pelfox/quego/internal"
+	"github.com/Pelfox/quego/internal/cron"
+	"github.com/Pelfox/quego/internal/dto"
+	"github.com/Pelfox/quego/internal/services"
+	"github.com/Pelfox/quego/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// createSchedule handles `POST /schedules` requests. It validates the CRON
+// expression and the target function, then persists the schedule.
+func (s *Server) createSchedule(ctx echo.Context) error {
+	var schedulePayload dto.CreateScheduleDTO
+	if err := ctx.Bind(&schedulePayload); err != nil {
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeInvalidBody,
+			"Failed to parse request body",
+		)
+	}
+
+	schedule := models.Schedule{
+		FunctionName: schedulePayload.FunctionName,
+		Expression:   schedulePayload.Expression,
+		Payload:      schedulePayload.Payload,
+		Enabled:      schedulePayload.Enabled == nil || *schedulePayload.Enabled,
+	}
+	if err := s.scheduleService.Create(&schedule); err != nil {
+		return respondScheduleError(ctx, err, "Failed to create schedule")
+	}
+
+	return ctx.JSON(http.StatusCreated, schedule)
+}
+
+// listSchedules handles `GET /schedules` requests. It retrieves all schedules
+// and returns them as JSON.
+func (s *Server) listSchedules(ctx echo.Context) error {
+	schedules, err := s.scheduleService.ListAll()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve schedules")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeDatabase,
+			"Failed to retrieve schedules",
+		)
+	}
+	return ctx.JSON(http.StatusOK, schedules)
+}
+
+// getSchedule handles `GET /schedules/:id` requests. It retrieves a single
+// schedule by its UUID and returns it as JSON.
+func (s *Server) getSchedule(ctx echo.Context) error {
+	scheduleID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid schedule ID")
+	}
+
+	schedule, err := s.scheduleService.GetByID(scheduleID)
+	if err != nil {
+		log.Error().Err(err).Str("id", scheduleID.String()).Msg("failed to get schedule")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeDatabase,
+			"Failed to retrieve schedule",
+		)
+	}
+	if schedule == nil {
+		return respondScheduleError(ctx, services.ErrScheduleNotFound, "")
+	}
+
+	return ctx.JSON(http.StatusOK, schedule)
+}
+
+// updateSchedule handles `PATCH /schedules/:id` requests. Only the fields
+// present in the request body are changed; the next run time is recomputed.
+func (s *Server) updateSchedule(ctx echo.Context) error {
+	scheduleID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid schedule ID")
+	}
+
+	var schedulePayload dto.UpdateScheduleDTO
+	if err := ctx.Bind(&schedulePayload); err != nil {
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeInvalidBody,
+			"Failed to parse request body",
+		)
+	}
+
+	schedule, err := s.scheduleService.Update(scheduleID, func(schedule *models.Schedule) {
+		if schedulePayload.Expression != nil {
+			schedule.Expression = *schedulePayload.Expression
+		}
+		if schedulePayload.Payload != nil {
+			schedule.Payload = *schedulePayload.Payload
+		}
+		if schedulePayload.Enabled != nil {
+			schedule.Enabled = *schedulePayload.Enabled
+		}
+	})
+	if err != nil {
+		return respondScheduleError(ctx, err, "Failed to update schedule")
+	}
+
+	return ctx.JSON(http.StatusOK, schedule)
+}
+
+// deleteSchedule handles `DELETE /schedules/:id` requests.
+func (s *Server) deleteSchedule(ctx echo.Context) error {
+	scheduleID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid schedule ID")
+	}
+
+	if err := s.scheduleService.Delete(scheduleID); err != nil {
+		return respondScheduleError(ctx, err, "Failed to delete schedule")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// respondScheduleError maps errors returned by the `ScheduleService` to API
+// error responses. Unexpected errors are logged and reported with the given
+// message.
+func respondScheduleError(ctx echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusNotFound,
+			internal.ErrorCodeNotFound,
+			"Schedule not found",
+		)
+	case errors.Is(err, services.ErrFunctionNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeFunctionNotFound,
+			"The requested function is not registered",
+		)
+	case errors.Is(err, cron.ErrInvalidExpression):
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeInvalidCronExpression,
+			err.Error(),
+		)
+	}
+
+	log.Error().Err(err).Msg(message)
+	return internal.RespondError(
+		ctx,
+		http.StatusInternalServerError,
+		internal.ErrorCodeDatabase,
+		message,
+	)
+}
+
+// parseIDParam parses the `:id` path parameter as a UUID. It reports false if
+// the parameter is not a valid UUID.
+func parseIDParam(ctx echo.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	return id, err == nil
+}
+
+// respondInvalidID responds with a `400 Bad Request` for a malformed `:id`
+// path parameter.
+func respondInvalidID(ctx echo.Context, message string) error {
+	return internal.RespondError(
+		ctx,
+		http.StatusBadRequest,
+		internal.ErrorCodeInvalidBody,
+		message,
+	)
+}
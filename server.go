@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Pelfox/quego/internal"
 	"github.com/Pelfox/quego/internal/dto"
@@ -22,68 +25,212 @@ import (
 
 // ServerConfig holds configuration options for the Server.
 type ServerConfig struct {
-	// RedisAddr is the address of the Redis server.
+	// RedisOptions configures the connection to the Redis server holding the
+	// queues, which may be shared by any number of instances. It is required
+	// by `QueueBackendRedis`.
 	RedisOptions *redis.Options
-	// WorkersCount is the number of concurrent workers to process function executions.
+	// QueueBackend selects where the queues are kept. Defaults to
+	// `QueueBackendRedis` if `RedisOptions` is set, and to
+	// `QueueBackendSQLite` otherwise.
+	QueueBackend QueueBackend
+	// WorkersCount is the number of concurrent workers to process function
+	// executions on the default queue, unless set in `Queues`.
 	WorkersCount int
+	// Queues maps the names of the queues served by this instance to their
+	// number of concurrent workers. Functions are assigned to a queue with
+	// `models.WithQueue`. A queue with no workers is not served by this
+	// instance, e.g. to leave the default queue to other instances.
+	Queues map[string]int
 	// CORSOrigins is the string of allowed origins for CORS.
 	CORSOrigins []string
 	// SQLitePath is the path to the SQLite database.
 	SQLitePath string
+	// IdempotencyWindow is how long an idempotency key passed to
+	// `POST /trigger` is remembered. Repeating a request with the same key
+	// within the window returns the original execution instead of creating a
+	// new one. Defaults to `DefaultIdempotencyWindow`.
+	IdempotencyWindow time.Duration
 }
 
+// QueueBackend identifies where the queues of a `Server` are kept.
+type QueueBackend string
+
+const (
+	// QueueBackendRedis keeps the queues in Redis. Any number of instances
+	// may share them, and limits and cancellations apply across all of them.
+	QueueBackendRedis QueueBackend = "redis"
+	// QueueBackendSQLite keeps the queues in the SQLite database, alongside
+	// the executions, so that no Redis server is needed. Limits and
+	// cancellations only apply within a single instance, so it suits
+	// deployments with one instance.
+	QueueBackendSQLite QueueBackend = "sqlite"
+	// QueueBackendMemory keeps the queues in memory. Queued jobs are lost on
+	// restart, so it is mostly meant for tests.
+	QueueBackendMemory QueueBackend = "memory"
+)
+
+const (
+	// DefaultIdempotencyWindow is the default value of
+	// `ServerConfig.IdempotencyWindow`.
+	DefaultIdempotencyWindow = 24 * time.Hour
+	// idempotencyKeyHeader is the request header carrying the idempotency key
+	// of a `POST /trigger` request.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader is set on responses that return the result of
+	// an earlier request with the same idempotency key.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// nextCursorHeader is set on responses of paginated listings that are
+	// followed by another page, to the value of the `cursor` query parameter
+	// retrieving it.
+	nextCursorHeader = "Next-Cursor"
+	// defaultPageSize is the number of items returned by paginated listings
+	// unless the `limit` query parameter is set.
+	defaultPageSize = 50
+	// maxPageSize is the maximum value of the `limit` query parameter of
+	// paginated listings.
+	maxPageSize = 200
+)
+
 // Server represents the HTTP API server. It wires together the Echo instance
 // with services and repositories that provide business and persistence logic.
 type Server struct {
 	app *echo.Echo
+	db  *sqlx.DB
+	// redis is the Redis client backing the queues, or nil if they are kept
+	// elsewhere.
+	redis *redis.Client
+	// stop cancels the context of the background goroutines launched by
+	// `Start`.
+	stop context.CancelFunc
 
 	config           *ServerConfig
 	executionService *services.ExecutionService
 	triggerService   *services.TriggerService
+	scheduleService  *services.ScheduleService
 }
 
 // NewServer initializes and returns a new Server instance. It creates a SQLite
-// database connection, configures repositories, and wires up the execution and
-// trigger services.
+// database connection, configures repositories, and wires up the execution,
+// trigger and schedule services.
 func NewServer(config ServerConfig) (*Server, error) {
-	db, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s", config.SQLitePath))
+	db, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", config.SQLitePath))
 	if err != nil {
 		return nil, err
 	}
 
-	redis := redis.NewClient(config.RedisOptions)
+	if config.IdempotencyWindow <= 0 {
+		config.IdempotencyWindow = DefaultIdempotencyWindow
+	}
+
+	if config.QueueBackend == "" {
+		config.QueueBackend = QueueBackendSQLite
+		if config.RedisOptions != nil {
+			config.QueueBackend = QueueBackendRedis
+		}
+	}
+
+	executionRepository := repositories.NewSQLiteExecutionRepository(db)
+	var (
+		redisClient *redis.Client
+		queue       services.Queue
+		coordinator services.Coordinator = services.NewMemoryCoordinator()
+	)
+	switch config.QueueBackend {
+	case QueueBackendRedis:
+		if config.RedisOptions == nil {
+			db.Close()
+			return nil, errors.New("the redis queue backend requires RedisOptions")
+		}
+		redisClient = redis.NewClient(config.RedisOptions)
+		queue = services.NewRedisQueue(redisClient)
+		coordinator = services.NewRedisCoordinator(redisClient)
+	case QueueBackendSQLite:
+		queue = services.NewSQLiteQueue(executionRepository, repositories.NewDeadLetterRepository(db))
+	case QueueBackendMemory:
+		queue = services.NewMemoryQueue()
+	default:
+		db.Close()
+		return nil, fmt.Errorf("unknown queue backend %q", config.QueueBackend)
+	}
+
 	app := echo.New()
 	app.HideBanner = true
 	app.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: config.CORSOrigins,
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+		AllowMethods: []string{
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodDelete,
+			http.MethodOptions,
+		},
+		ExposeHeaders: []string{idempotentReplayedHeader, nextCursorHeader},
 	}))
 
+	queues := make(map[string]int, len(config.Queues)+1)
+	queues[models.DefaultQueue] = max(config.WorkersCount, 1)
+	for queue, workersCount := range config.Queues {
+		queues[queue] = workersCount
+	}
+
+	executionService := services.NewExecutionService(
+		queues,
+		queue,
+		coordinator,
+		executionRepository,
+	)
+	triggerService := services.NewTriggerService(
+		repositories.NewSQLiteTriggerRepository(db),
+	)
+
 	return &Server{
-		config: &config,
-		app:    app,
-		executionService: services.NewExecutionService(
-			config.WorkersCount,
-			redis,
-			repositories.NewExecutionRepository(db),
-		),
-		triggerService: services.NewTriggerService(
-			repositories.NewTriggerRepository(db),
+		config:           &config,
+		app:              app,
+		db:               db,
+		redis:            redisClient,
+		executionService: executionService,
+		triggerService:   triggerService,
+		scheduleService: services.NewScheduleService(
+			repositories.NewScheduleRepository(db),
+			triggerService,
+			executionService,
 		),
 	}, nil
 }
 
 // RegisterFunction registers a function with the ExecutionService. Registered
-// functions can later be invoked via triggers.
-func (s *Server) RegisterFunction(name string, f models.ExecFunction) {
-	s.executionService.RegisterFunction(name, f)
+// functions can later be invoked via triggers. Options such as
+// `models.WithRetryPolicy` control how its executions are handled.
+func (s *Server) RegisterFunction(name string, f models.ExecFunction, opts ...models.FunctionOption) {
+	s.executionService.RegisterFunction(name, f.WithContext().WithResult(), opts...)
+}
+
+// RegisterContextFunction registers a context-aware function with the
+// ExecutionService. It behaves like `RegisterFunction`, but the function
+// receives a context that is cancelled when the execution must stop and that
+// carries the execution ID and attempt number.
+func (s *Server) RegisterContextFunction(name string, f models.ContextFunction, opts ...models.FunctionOption) {
+	s.executionService.RegisterFunction(name, f.WithResult(), opts...)
+}
+
+// RegisterResultFunction registers a context-aware function that produces a
+// result with the ExecutionService. It behaves like
+// `RegisterContextFunction`, but the value returned by a successful execution
+// is stored as JSON and exposed through `GET /executions/:id`.
+func (s *Server) RegisterResultFunction(name string, f models.ResultFunction, opts ...models.FunctionOption) {
+	s.executionService.RegisterFunction(name, f, opts...)
 }
 
 // triggerRoute handles `POST /trigger` requests.
 // Flow:
 //  1. Client submits a trigger in the request body.
 //  2. The trigger is parsed and saved in the database.
-//  3. The corresponding function is executed.
+//  3. The corresponding function is executed, either right away or, if the
+//     trigger specifies `run_at` or `delay`, once that time has come.
+//
+// If the request carries an idempotency key (in the `Idempotency-Key` header
+// or the `idempotency_key` field) that has been used within the idempotency
+// window, steps 2 and 3 are skipped and the original execution is returned.
 func (s *Server) triggerRoute(ctx echo.Context) error {
 	var triggerPayload dto.CreateTriggerDTO
 	if err := ctx.Bind(&triggerPayload); err != nil {
@@ -95,40 +242,132 @@ func (s *Server) triggerRoute(ctx echo.Context) error {
 		)
 	}
 
+	var runAt time.Time
+	switch {
+	case triggerPayload.RunAt != nil && triggerPayload.Delay != "":
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeInvalidBody,
+			"Only one of run_at and delay may be specified",
+		)
+	case triggerPayload.RunAt != nil:
+		runAt = *triggerPayload.RunAt
+	case triggerPayload.Delay != "":
+		delay, err := time.ParseDuration(triggerPayload.Delay)
+		if err != nil || delay < 0 {
+			return internal.RespondError(
+				ctx,
+				http.StatusBadRequest,
+				internal.ErrorCodeInvalidBody,
+				"Invalid delay, expected a non-negative duration such as \"90s\"",
+			)
+		}
+		runAt = time.Now().Add(delay)
+	}
+
 	trigger := models.Trigger{
 		TriggerType:  models.TriggerTypeEvent,
 		FunctionName: triggerPayload.FunctionName,
 		Payload:      triggerPayload.Payload,
+		Priority:     triggerPayload.Priority,
 	}
-	if err := s.triggerService.Create(&trigger); err != nil {
-		log.Error().Err(err).Msg("failed to create trigger")
+	if key := ctx.Request().Header.Get(idempotencyKeyHeader); key != "" {
+		trigger.IdempotencyKey = &key
+	} else if triggerPayload.IdempotencyKey != "" {
+		trigger.IdempotencyKey = &triggerPayload.IdempotencyKey
+	}
+
+	execution, err := s.executionService.NewExecution(&trigger, runAt)
+	if err != nil {
 		return internal.RespondError(
 			ctx,
-			http.StatusInternalServerError,
-			internal.ErrorCodeDatabase,
-			"Failed to create trigger",
+			http.StatusBadRequest,
+			internal.ErrorCodeFunctionNotFound,
+			"The requested function is not registered",
 		)
 	}
 
-	execution, err := s.executionService.Process(&trigger)
-	if err != nil {
-		if errors.Is(err, services.ErrFunctionNotFound) {
+	if trigger.IdempotencyKey != nil {
+		existing, created, err := s.triggerService.CreateIdempotent(&trigger, execution, s.config.IdempotencyWindow)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create trigger")
 			return internal.RespondError(
 				ctx,
-				http.StatusBadRequest,
-				internal.ErrorCodeFunctionNotFound,
-				"The requested function is not registered",
+				http.StatusInternalServerError,
+				internal.ErrorCodeDatabase,
+				"Failed to create trigger",
 			)
 		}
+		if !created {
+			return s.replayTrigger(ctx, &trigger, existing)
+		}
+	} else if err := s.triggerService.Create(&trigger, execution); err != nil {
+		log.Error().Err(err).Msg("failed to create trigger")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeDatabase,
+			"Failed to create trigger",
+		)
+	}
+
+	if err := s.executionService.QueueExecution(&trigger, execution); err != nil {
 		log.Error().Err(err).Str("id", trigger.ID.String()).Msg("failed to enqueue trigger")
+		// Discard the trigger, so that a retry of the request with the same
+		// idempotency key is processed again instead of being replayed.
+		if err := s.triggerService.Discard(*trigger.ID); err != nil {
+			log.Error().Err(err).Str("id", trigger.ID.String()).Msg("failed to discard trigger")
+		}
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeQueue,
+			"Failed to enqueue execution",
+		)
+	}
+
+	return ctx.JSON(http.StatusOK, execution)
+}
+
+// replayTrigger responds to a `POST /trigger` request whose idempotency key
+// has already been used by the `existing` trigger. It returns the execution of
+// the existing trigger, as long as the request asks for the same function with
+// the same payload and priority.
+func (s *Server) replayTrigger(ctx echo.Context, trigger *models.Trigger, existing *models.Trigger) error {
+	if trigger.FunctionName != existing.FunctionName ||
+		trigger.Payload != existing.Payload ||
+		trigger.Priority != existing.Priority {
+		return internal.RespondError(
+			ctx,
+			http.StatusUnprocessableEntity,
+			internal.ErrorCodeIdempotencyKeyReused,
+			"The idempotency key has already been used for a different trigger",
+		)
+	}
+
+	execution, err := s.executionService.GetLatestByTriggerID(*existing.ID)
+	if err != nil {
+		log.Error().Err(err).Str("id", existing.ID.String()).Msg("failed to get execution")
 		return internal.RespondError(
 			ctx,
 			http.StatusInternalServerError,
 			internal.ErrorCodeDatabase,
-			"Failed to process trigger",
+			"Failed to retrieve execution",
+		)
+	}
+	if execution == nil {
+		// Triggers are stored along with their first execution, except for
+		// those created by older versions before enqueueing.
+		return internal.RespondError(
+			ctx,
+			http.StatusConflict,
+			internal.ErrorCodeIdempotencyKeyInUse,
+			"A request with the same idempotency key is still being processed",
 		)
 	}
 
+	ctx.Response().Header().Set(idempotentReplayedHeader, "true")
 	return ctx.JSON(http.StatusOK, execution)
 }
 
@@ -168,10 +407,74 @@ func (s *Server) getExecution(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, execution)
 }
 
-// ListExecutions handles `GET /executions` requests. It retrieves all
-// executions and returns them as JSON.
+// cancelExecution handles `POST /executions/:id/cancel` requests. A pending
+// execution is cancelled right away and returned with `200 OK`. For a running
+// execution, the cancellation is requested and the execution is returned with
+// `202 Accepted`; its status changes once its function has stopped.
+func (s *Server) cancelExecution(ctx echo.Context) error {
+	executionID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid execution ID")
+	}
+
+	execution, err := s.executionService.CancelExecution(executionID)
+	switch {
+	case err == nil:
+		if execution.Status == models.ExecutionStatusRunning {
+			return ctx.JSON(http.StatusAccepted, execution)
+		}
+		return ctx.JSON(http.StatusOK, execution)
+	case errors.Is(err, services.ErrExecutionNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusNotFound,
+			internal.ErrorCodeNotFound,
+			"Execution not found",
+		)
+	case errors.Is(err, services.ErrExecutionNotCancellable):
+		return internal.RespondError(
+			ctx,
+			http.StatusConflict,
+			internal.ErrorCodeExecutionNotCancellable,
+			"The execution has already finished",
+		)
+	}
+
+	log.Error().Err(err).Str("id", executionID.String()).Msg("failed to cancel execution")
+	return internal.RespondError(
+		ctx,
+		http.StatusInternalServerError,
+		internal.ErrorCodeDatabase,
+		"Failed to cancel execution",
+	)
+}
+
+// ListExecutions handles `GET /executions` requests. It retrieves a page of
+// executions, newest first, and returns them as JSON. The following query
+// parameters are supported:
+//   - `status`: comma-separated statuses the executions must be in;
+//   - `function_name`: the function the executions must belong to;
+//   - `trigger_type`: the type of the trigger of the executions;
+//   - `created_after` and `created_before`: RFC 3339 timestamps restricting
+//     the creation time of the executions, the former inclusively;
+//   - `limit`: the maximum number of executions to return, up to
+//     `maxPageSize`;
+//   - `cursor`: the position to continue a listing at, as returned in the
+//     `Next-Cursor` header of the previous page.
+//
+// The `Next-Cursor` header is only set if there are more executions.
 func (s *Server) ListExecutions(ctx echo.Context) error {
-	executions, err := s.executionService.ListAllTriggers()
+	filter, err := parseExecutionFilter(ctx)
+	if err != nil {
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeInvalidQuery,
+			"Invalid query parameters: "+err.Error(),
+		)
+	}
+
+	executions, next, err := s.executionService.ListExecutions(filter)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to retrieve executions")
 		return internal.RespondError(
@@ -181,18 +484,84 @@ func (s *Server) ListExecutions(ctx echo.Context) error {
 			"Failed to retrieve executions",
 		)
 	}
+	if executions == nil {
+		executions = []*models.ExecutionWithTrigger{}
+	}
+	if next != nil {
+		ctx.Response().Header().Set(nextCursorHeader, next.String())
+	}
 	return ctx.JSON(http.StatusOK, executions)
 }
 
+// parseExecutionFilter parses the query parameters of a `GET /executions`
+// request. The returned error describes the malformed parameter.
+func parseExecutionFilter(ctx echo.Context) (repositories.ExecutionFilter, error) {
+	filter := repositories.ExecutionFilter{
+		FunctionName: ctx.QueryParam("function_name"),
+		TriggerType:  models.TriggerType(ctx.QueryParam("trigger_type")),
+	}
+
+	if value := ctx.QueryParam("status"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			status := models.ExecutionStatus(strings.TrimSpace(name))
+			if !status.IsValid() {
+				return filter, fmt.Errorf("unknown status %q", name)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	if filter.TriggerType != "" && !filter.TriggerType.IsValid() {
+		return filter, fmt.Errorf("unknown trigger type %q", filter.TriggerType)
+	}
+
+	for param, target := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if value := ctx.QueryParam(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+			}
+			*target = &parsed
+		}
+	}
+
+	limit, after, err := parsePage(ctx)
+	filter.Limit, filter.After = limit, after
+	return filter, err
+}
+
+// parsePage parses the `limit` and `cursor` query parameters of a paginated
+// listing. The returned error describes the malformed parameter.
+func parsePage(ctx echo.Context) (int, *repositories.Cursor, error) {
+	limit := defaultPageSize
+	if value := ctx.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			return 0, nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = parsed
+	}
+
+	var after *repositories.Cursor
+	if value := ctx.QueryParam("cursor"); value != "" {
+		cursor, err := repositories.ParseCursor(value)
+		if err != nil {
+			return 0, nil, errors.New("malformed cursor")
+		}
+		after = cursor
+	}
+	return limit, after, nil
+}
+
 // Start runs the HTTP server at the given address. Before starting,
-// it ensures the database schema is migrated.
+// it ensures the database schema is migrated. It blocks until the server
+// stops, and returns nil if it was stopped by `Shutdown`.
 func (s *Server) Start(addr string) error {
 	if err := internal.MigrateDatabase(s.config.SQLitePath); err != nil {
 		return err
 	}
-	if err := s.executionService.RequeueStaled(); err != nil {
-		return err
-	}
 
 	// logging middleware
 	s.app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -207,9 +576,49 @@ func (s *Server) Start(addr string) error {
 		}
 	})
 
-	s.executionService.StartWorkers(context.Background())
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.executionService.StartWorkers(ctx)
+	s.scheduleService.Start(ctx)
 	s.app.POST("/trigger", s.triggerRoute)
+	s.app.GET("/functions", s.listFunctions)
+	s.app.GET("/triggers", s.listTriggers)
+	s.app.GET("/triggers/:id", s.getTrigger)
+	s.app.DELETE("/triggers/:id", s.deleteTrigger)
 	s.app.GET("/executions", s.ListExecutions)
 	s.app.GET("/executions/:id", s.getExecution)
-	return s.app.Start(addr)
+	s.app.POST("/executions/:id/cancel", s.cancelExecution)
+	s.app.POST("/schedules", s.createSchedule)
+	s.app.GET("/schedules", s.listSchedules)
+	s.app.GET("/schedules/:id", s.getSchedule)
+	s.app.PATCH("/schedules/:id", s.updateSchedule)
+	s.app.DELETE("/schedules/:id", s.deleteSchedule)
+	s.app.GET("/dead-letters", s.listDeadLetters)
+	s.app.GET("/dead-letters/:id", s.getDeadLetter)
+	s.app.POST("/dead-letters/:id/requeue", s.requeueDeadLetter)
+	s.app.DELETE("/dead-letters", s.purgeDeadLetters)
+	if err := s.app.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the server. It stops accepting HTTP requests,
+// stops firing schedules once those being fired are done, stops dequeuing
+// jobs and waits for the running executions to finish. If the provided context is done before that, the remaining
+// executions are interrupted and requeued. Finally, the database and Redis
+// connections are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	httpErr := s.app.Shutdown(ctx)
+	if s.stop != nil {
+		s.stop()
+	}
+	s.scheduleService.Stop()
+	workersErr := s.executionService.Stop(ctx)
+
+	var redisErr error
+	if s.redis != nil {
+		redisErr = s.redis.Close()
+	}
+	return errors.Join(httpErr, workersErr, redisErr, s.db.Close())
 }
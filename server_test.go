@@ -0,0 +1,164 @@
+package quego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/internal/services"
+	"github.com/Pelfox/quego/models"
+	"github.com/labstack/echo/v4"
+)
+
+// failingQueue is a `services.Queue` whose `Enqueue` fails while `fail` is
+// set.
+type failingQueue struct {
+	services.Queue
+	fail bool
+}
+
+func (q *failingQueue) Enqueue(ctx context.Context, queue string, job string) error {
+	if q.fail {
+		return errors.New("queue unavailable")
+	}
+	return q.Queue.Enqueue(ctx, queue, job)
+}
+
+// newTestServer returns a `Server` backed by in-memory repositories and the
+// given queue, with a no-op function named "noop" registered.
+func newTestServer(queue services.Queue) *Server {
+	store := repositories.NewMemoryStore()
+	s := &Server{
+		app:    echo.New(),
+		config: &ServerConfig{IdempotencyWindow: DefaultIdempotencyWindow},
+		executionService: services.NewExecutionService(
+			map[string]int{models.DefaultQueue: 1},
+			queue,
+			services.NewMemoryCoordinator(),
+			store.Executions(),
+		),
+		triggerService: services.NewTriggerService(store.Triggers()),
+	}
+	s.RegisterFunction("noop", func(*models.Trigger) error { return nil })
+	return s
+}
+
+// postTrigger calls `triggerRoute` with the given body and idempotency key.
+func postTrigger(t *testing.T, s *Server, body string, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/trigger", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(idempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	if err := s.triggerRoute(s.app.NewContext(req, rec)); err != nil {
+		t.Fatalf("triggerRoute: %v", err)
+	}
+	return rec
+}
+
+func TestTriggerRouteEnqueueFailure(t *testing.T) {
+	queue := &failingQueue{Queue: services.NewMemoryQueue(), fail: true}
+	s := newTestServer(queue)
+	body := `{"function_name": "noop"}`
+
+	rec := postTrigger(t, s, body, "key")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("failed enqueue: got status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	triggers, _, err := s.triggerService.List(repositories.TriggerFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 0 {
+		t.Fatalf("failed enqueue: %d triggers kept, want none", len(triggers))
+	}
+
+	// The retry must be processed again, rather than replayed or rejected.
+	queue.fail = false
+	rec = postTrigger(t, s, body, "key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry: got status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec.Header().Get(idempotentReplayedHeader) != "" {
+		t.Fatal("retry: response is a replay")
+	}
+	var execution models.Execution
+	if err := json.Unmarshal(rec.Body.Bytes(), &execution); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := queue.Dequeue(context.Background(), models.DefaultQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(job, execution.ID.String()) {
+		t.Fatalf("retry: queued job %s is not execution %s", job, execution.ID)
+	}
+
+	// Once enqueued, the request is replayed.
+	rec = postTrigger(t, s, body, "key")
+	if rec.Code != http.StatusOK || rec.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("replay: got status %d, replayed %q", rec.Code, rec.Header().Get(idempotentReplayedHeader))
+	}
+}
+
+func TestRequeueDeadLetterOfDeletedTrigger(t *testing.T) {
+	queue := services.NewMemoryQueue()
+	s := newTestServer(queue)
+
+	rec := postTrigger(t, s, `{"function_name": "noop"}`, "key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("trigger: got status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var execution models.Execution
+	if err := json.Unmarshal(rec.Body.Bytes(), &execution); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := queue.Dequeue(context.Background(), models.DefaultQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := json.Marshal(models.DeadLetter{
+		ID:          execution.ID,
+		ExecutionID: &execution.ID,
+		Reason:      models.DeadLetterReasonRetriesExhausted,
+		Job:         job,
+		DeadAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Bury(context.Background(), string(entry)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.triggerService.Discard(execution.TriggerID); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/dead-letters/"+execution.ID.String()+"/requeue", nil)
+	rec = httptest.NewRecorder()
+	ctx := s.app.NewContext(req, rec)
+	ctx.SetParamNames("id")
+	ctx.SetParamValues(execution.ID.String())
+	if err := s.requeueDeadLetter(ctx); err != nil {
+		t.Fatalf("requeueDeadLetter: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("requeue: got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	deadLetters, err := queue.DeadLetters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("requeue: %d dead letters left, want 1", len(deadLetters))
+	}
+}
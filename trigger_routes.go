@@ -0,0 +1,141 @@
+package quego
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pelfox/quego/internal"
+	"github.com/Pelfox/quego/internal/repositories"
+	"github.com/Pelfox/quego/internal/services"
+	"github.com/Pelfox/quego/models"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// listTriggers handles `GET /triggers` requests. It retrieves a page of
+// triggers, newest first, and returns them as JSON. The `function_name`,
+// `trigger_type`, `limit` and `cursor` query parameters are supported, with
+// the same meaning as for `GET /executions`.
+func (s *Server) listTriggers(ctx echo.Context) error {
+	filter, err := parseTriggerFilter(ctx)
+	if err != nil {
+		return internal.RespondError(
+			ctx,
+			http.StatusBadRequest,
+			internal.ErrorCodeInvalidQuery,
+			"Invalid query parameters: "+err.Error(),
+		)
+	}
+
+	triggers, next, err := s.triggerService.List(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to retrieve triggers")
+		return internal.RespondError(
+			ctx,
+			http.StatusInternalServerError,
+			internal.ErrorCodeDatabase,
+			"Failed to retrieve triggers",
+		)
+	}
+	if triggers == nil {
+		triggers = []*models.Trigger{}
+	}
+	if next != nil {
+		ctx.Response().Header().Set(nextCursorHeader, next.String())
+	}
+	return ctx.JSON(http.StatusOK, triggers)
+}
+
+// getTrigger handles `GET /triggers/:id` requests. It retrieves a single
+// trigger by its UUID, along with every execution it has spawned, and returns
+// them as JSON.
+func (s *Server) getTrigger(ctx echo.Context) error {
+	triggerID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid trigger ID")
+	}
+
+	trigger, err := s.triggerService.GetByID(triggerID)
+	if err != nil {
+		return respondTriggerError(ctx, err, "Failed to retrieve trigger")
+	}
+	if trigger == nil {
+		return respondTriggerError(ctx, services.ErrTriggerNotFound, "")
+	}
+
+	executions, _, err := s.executionService.ListExecutions(repositories.ExecutionFilter{TriggerID: &triggerID})
+	if err != nil {
+		return respondTriggerError(ctx, err, "Failed to retrieve trigger executions")
+	}
+
+	response := models.TriggerWithExecutions{
+		Trigger:    *trigger,
+		Executions: make([]*models.Execution, len(executions)),
+	}
+	for i, execution := range executions {
+		response.Executions[i] = &execution.Execution
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// deleteTrigger handles `DELETE /triggers/:id` requests. The executions of
+// the trigger are deleted along with it, which is refused while one of them
+// is pending or running.
+func (s *Server) deleteTrigger(ctx echo.Context) error {
+	triggerID, ok := parseIDParam(ctx)
+	if !ok {
+		return respondInvalidID(ctx, "Invalid trigger ID")
+	}
+
+	if err := s.triggerService.Delete(triggerID); err != nil {
+		return respondTriggerError(ctx, err, "Failed to delete trigger")
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// parseTriggerFilter parses the query parameters of a `GET /triggers`
+// request. The returned error describes the malformed parameter.
+func parseTriggerFilter(ctx echo.Context) (repositories.TriggerFilter, error) {
+	filter := repositories.TriggerFilter{
+		FunctionName: ctx.QueryParam("function_name"),
+		TriggerType:  models.TriggerType(ctx.QueryParam("trigger_type")),
+	}
+	if filter.TriggerType != "" && !filter.TriggerType.IsValid() {
+		return filter, fmt.Errorf("unknown trigger type %q", filter.TriggerType)
+	}
+
+	limit, after, err := parsePage(ctx)
+	filter.Limit, filter.After = limit, after
+	return filter, err
+}
+
+// respondTriggerError maps errors returned by the `TriggerService` to API
+// error responses. Unexpected errors are logged and reported with the given
+// message.
+func respondTriggerError(ctx echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrTriggerNotFound):
+		return internal.RespondError(
+			ctx,
+			http.StatusNotFound,
+			internal.ErrorCodeNotFound,
+			"Trigger not found",
+		)
+	case errors.Is(err, services.ErrTriggerActive):
+		return internal.RespondError(
+			ctx,
+			http.StatusConflict,
+			internal.ErrorCodeTriggerActive,
+			"The trigger has a pending or running execution",
+		)
+	}
+
+	log.Error().Err(err).Msg(message)
+	return internal.RespondError(
+		ctx,
+		http.StatusInternalServerError,
+		internal.ErrorCodeDatabase,
+		message,
+	)
+}